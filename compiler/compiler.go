@@ -0,0 +1,855 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/code"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/object"
+	"github.com/st0012/monkey/token"
+	"strings"
+)
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+var prefixOperators = map[string]code.Opcode{
+	"!": code.OpBang,
+	"-": code.OpMinus,
+	"~": code.OpBitNot,
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// pos is the position of the node being compiled, which the
+	// instructions emitted for it are attributed to.
+	pos token.Position
+
+	loader *core.Loader
+	// moduleInits holds the constant index of the function that runs each
+	// module loaded by this compiler.
+	moduleInits map[*object.Module]int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loopScope
+	positions           map[int]token.Position
+	// cleanups are what a break, continue or return has to do before it
+	// leaves the try statements it's in, innermost last.
+	cleanups []cleanup
+}
+
+type cleanupKind int
+
+const (
+	// endTry removes the handler of a try statement.
+	endTry cleanupKind = iota
+	// runFinally removes the handler of a try statement and runs its
+	// finally block.
+	runFinally
+	// popValue drops a value the statement keeps on the stack.
+	popValue
+)
+
+type cleanup struct {
+	kind    cleanupKind
+	finally *ast.BlockStatement
+}
+
+// loopScope tracks the jump targets of the loop being compiled.
+type loopScope struct {
+	// cleanupDepth is the number of cleanups outside of the loop, which a
+	// break or continue leaves alone.
+	cleanupDepth int
+	continuePos  int
+	// breakJumps are the positions of the jumps that still need to be
+	// pointed at the end of the loop.
+	breakJumps []int
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// GlobalNames holds the name of each global slot, for error messages.
+	GlobalNames []string
+	// ConstGlobals marks the global slots that hold constants.
+	ConstGlobals []bool
+	// Positions maps the offset of each instruction to its source position.
+	Positions map[int]token.Position
+}
+
+// Error is a compile error. Its message matches the one the evaluator
+// reports at runtime for the same mistake.
+type Error struct {
+	Message string
+	Pos     token.Position
+}
+
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+	return e.Message
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{instructions: code.Instructions{}, positions: map[int]token.Position{}}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		loader:      core.NewLoader(nil),
+		moduleInits: map[*object.Module]int{},
+	}
+}
+
+// NewWithState returns a compiler that keeps defining globals and constants
+// on top of the given ones, so a REPL session can compile line by line.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// SetModulePaths sets the directories an import is looked up in when the
+// file isn't next to the file that imports it.
+func (c *Compiler) SetModulePaths(paths []string) {
+	c.loader = core.NewLoader(paths)
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	outer := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = outer }()
+
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		// The frame's stack goes away with the return, so the values the
+		// enclosing statements keep don't need popping.
+		if err := c.unwind(0, false); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if err := c.unwind(loop.cleanupDepth, true); err != nil {
+			return err
+		}
+		loop.breakJumps = append(loop.breakJumps, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if err := c.unwind(loop.cleanupDepth, true); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loop.continuePos)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryStatement:
+		return c.compileTryStatement(node)
+
+	// Expressions
+	case *ast.Identifier:
+		c.compileIdentifier(node)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.BigIntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.DecimalLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Decimal{Value: node.Value, Scale: node.Scale}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return newError(node, "unknown operator: %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return newError(node, "unknown operator: %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionExpression:
+		return c.compileFunctionExpression(node)
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ImportStatement:
+		return c.compileImportStatement(node)
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+	case *ast.MemberExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpGetMember, c.addConstant(&object.String{Value: node.Member.Value}))
+	default:
+		return newError(node, "unsupported node: %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Global().Names(),
+		ConstGlobals: c.symbolTable.Global().ConstSlots(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+	}
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	var symbol Symbol
+
+	if c.symbolTable.IsConstInScope(node.Name.Value) {
+		return newError(node, "cannot redeclare constant: %s", node.Name.Value)
+	}
+
+	define := c.symbolTable.Define
+	if node.IsConst() {
+		define = c.symbolTable.DefineConst
+	}
+
+	// A function can refer to the name it's bound to, but any other value
+	// still sees the outer binding while it's being computed.
+	if _, ok := node.Value.(*ast.FunctionExpression); ok {
+		symbol = define(node.Name.Value)
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	if symbol.Name == "" {
+		symbol = define(node.Name.Value)
+	}
+
+	c.setSymbol(symbol)
+	return nil
+}
+
+// compileIdentifier loads a variable. Names that aren't defined yet resolve
+// to builtins or become globals, so, like the evaluator, a function can use
+// a global that is only defined after the function itself.
+func (c *Compiler) compileIdentifier(node *ast.Identifier) {
+	symbol, ok := c.symbolTable.Resolve(node.Value)
+	if !ok {
+		if index, isBuiltin := core.BuiltinIndex(node.Value); isBuiltin {
+			c.emit(code.OpGetBuiltin, index)
+			return
+		}
+
+		symbol = c.symbolTable.Global().Define(node.Value)
+	}
+
+	c.loadSymbol(symbol)
+}
+
+// compileAssignExpression compiles an assignment so it leaves the assigned
+// value on the stack. A compound assignment loads the current value first.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var op code.Opcode
+	operator := strings.TrimSuffix(node.Operator, "=")
+	if operator != "" {
+		var ok bool
+		if op, ok = infixOperators[operator]; !ok {
+			return newError(node, "unknown operator: %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, resolved := c.symbolTable.Resolve(target.Value)
+		if !resolved {
+			symbol = c.symbolTable.Global().Define(target.Value)
+		}
+
+		if symbol.Const {
+			return newError(node, "cannot assign to constant: %s", target.Value)
+		}
+
+		if operator != "" {
+			c.loadSymbol(symbol)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if operator != "" {
+			c.emit(op)
+		}
+
+		// The name may still be defined, maybe as a constant, before this
+		// runs, so the vm checks the global when it's assigned.
+		if resolved {
+			c.setSymbol(symbol)
+		} else {
+			c.emit(code.OpAssignGlobal, symbol.Index)
+		}
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if operator != "" {
+			c.emit(code.OpDupPair)
+			c.emit(code.OpIndex)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if operator != "" {
+			c.emit(op)
+		}
+
+		c.emit(code.OpSetIndex)
+	default:
+		return newError(node, "invalid assignment target: %s", node.Target.String())
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	jump := code.OpJumpNotTruthyOrPop
+	if node.Operator == "||" {
+		jump = code.OpJumpTruthyOrPop
+	}
+	jumpPos := c.emit(jump, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit an `OpJumpNotTruthy` with a bogus value, it's changed once the consequence is compiled.
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	// Emit an `OpJump` with a bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileWhileStatement compiles a while loop. Like every loop it evaluates
+// to null, which is left as the value of the statement.
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	loop := c.enterLoop()
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, loop.continuePos)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	c.leaveLoop()
+
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	loop := c.enterLoop()
+	exitPos := c.emit(code.OpIterNext, 9999)
+
+	if c.symbolTable.IsConstInScope(node.Variable.Value) {
+		return newError(node, "cannot redeclare constant: %s", node.Variable.Value)
+	}
+	c.setSymbol(c.symbolTable.Define(node.Variable.Value))
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, loop.continuePos)
+
+	// A break leaves the iterator on the stack, so it jumps to a pop.
+	c.leaveLoop()
+	c.emit(code.OpPop)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+func (c *Compiler) enterLoop() *loopScope {
+	loop := &loopScope{
+		cleanupDepth: len(c.scopes[c.scopeIndex].cleanups),
+		continuePos:  len(c.currentInstructions()),
+	}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, loop)
+	return loop
+}
+
+// leaveLoop points the loop's breaks at the next instruction.
+func (c *Compiler) leaveLoop() {
+	loops := c.scopes[c.scopeIndex].loops
+	loop := loops[len(loops)-1]
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+
+	for _, pos := range loop.breakJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
+
+func (c *Compiler) currentLoop() *loopScope {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+// compileTryStatement compiles a try statement so it leaves the value of
+// its try or catch block, like an expression statement. A finally block
+// is compiled on each way out of the statement: after the other blocks,
+// before a break, continue or return, and before an error that wasn't
+// caught is raised again.
+func (c *Compiler) compileTryStatement(node *ast.TryStatement) error {
+	var finallyPos int
+	if node.Finally != nil {
+		finallyPos = c.emit(code.OpTry, 9999)
+		c.pushCleanup(cleanup{kind: runFinally, finally: node.Finally})
+	}
+
+	if node.Catch != nil {
+		if err := c.compileTryCatch(node); err != nil {
+			return err
+		}
+	} else if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+
+	if node.Finally != nil {
+		c.popCleanup()
+		c.emit(code.OpEndTry)
+
+		// The value of the statement is on the stack while the finally
+		// block runs, like the error is when it runs after one.
+		c.pushCleanup(cleanup{kind: popValue})
+		if err := c.Compile(node.Finally); err != nil {
+			return err
+		}
+		c.popCleanup()
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(finallyPos, len(c.currentInstructions()))
+		c.pushCleanup(cleanup{kind: popValue})
+		if err := c.Compile(node.Finally); err != nil {
+			return err
+		}
+		c.popCleanup()
+		c.emit(code.OpThrow)
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileTryCatch compiles the try and catch blocks of a try statement. The
+// catch block gets the error value on the stack, and binds it to its
+// parameter in a scope of its own.
+func (c *Compiler) compileTryCatch(node *ast.TryStatement) error {
+	catchPos := c.emit(code.OpTry, 9999)
+	c.pushCleanup(cleanup{kind: endTry})
+
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+
+	c.popCleanup()
+	c.emit(code.OpEndTry)
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(catchPos, len(c.currentInstructions()))
+
+	c.symbolTable.EnterBlock()
+	defer c.symbolTable.LeaveBlock()
+
+	if node.CatchParam != nil {
+		c.setSymbol(c.symbolTable.Define(node.CatchParam.Value))
+	} else {
+		c.emit(code.OpPop)
+	}
+
+	if err := c.compileBlockValue(node.Catch); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) pushCleanup(cl cleanup) {
+	c.scopes[c.scopeIndex].cleanups = append(c.scopes[c.scopeIndex].cleanups, cl)
+}
+
+func (c *Compiler) popCleanup() {
+	cleanups := c.scopes[c.scopeIndex].cleanups
+	c.scopes[c.scopeIndex].cleanups = cleanups[:len(cleanups)-1]
+}
+
+// unwind emits the cleanups above depth, innermost first, for a jump out of
+// them. The values they keep are only popped if popValues is set.
+func (c *Compiler) unwind(depth int, popValues bool) error {
+	cleanups := c.scopes[c.scopeIndex].cleanups
+	defer func() { c.scopes[c.scopeIndex].cleanups = cleanups }()
+
+	for i := len(cleanups) - 1; i >= depth; i-- {
+		switch cleanups[i].kind {
+		case endTry:
+			c.emit(code.OpEndTry)
+		case runFinally:
+			c.emit(code.OpEndTry)
+
+			// A jump out of the finally block only runs the cleanups
+			// outside of it.
+			c.scopes[c.scopeIndex].cleanups = append([]cleanup{}, cleanups[:i]...)
+			if err := c.Compile(cleanups[i].finally); err != nil {
+				return err
+			}
+		case popValue:
+			if popValues {
+				c.emit(code.OpPop)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionExpression(node *ast.FunctionExpression) error {
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	if err := c.compileBlockValue(node.BlockStatement); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	localNames := c.symbolTable.Names()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	freeVariables := make([]object.FreeVariable, len(freeSymbols))
+	for i, s := range freeSymbols {
+		freeVariables[i] = object.FreeVariable{Name: s.Name, Local: s.Scope == LocalScope, Index: s.Index}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          node.Name,
+		Instructions:  instructions,
+		NumLocals:     len(localNames),
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		FreeVariables: freeVariables,
+		Positions:     positions,
+		Literal:       node,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn))
+	return nil
+}
+
+// compileBlockValue compiles a block so it leaves its value on the stack,
+// which is the value of its last statement like in the evaluator.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	switch last := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.WhileStatement, *ast.ForStatement, *ast.TryStatement:
+		c.removeLastPop()
+	case *ast.LetStatement:
+		symbol, _ := c.symbolTable.Resolve(last.Name.Value)
+		c.loadSymbol(symbol)
+	default:
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) setSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.scopes[c.scopeIndex].positions[pos] = c.pos
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	if !c.lastInstructionIs(code.OpPop) {
+		return
+	}
+
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	copy(c.currentInstructions()[opPos:], newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{instructions: code.Instructions{}, positions: map[int]token.Position{}}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func newError(node ast.Node, format string, args ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Pos: node.Pos()}
+}
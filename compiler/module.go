@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/code"
+	"github.com/st0012/monkey/object"
+)
+
+// compileImportStatement runs the module, unless it ran already, and binds
+// it to the import's name.
+func (c *Compiler) compileImportStatement(node *ast.ImportStatement) error {
+	loaded := c.loader.Import(node.Path.Value, node.Pos().Filename, c.compileModule)
+	if err, ok := loaded.(*object.Error); ok {
+		if !err.Pos.IsValid() {
+			err.Pos = node.Pos()
+		}
+		return &Error{Message: err.Message, Pos: err.Pos}
+	}
+
+	module := loaded.(*object.Module)
+	c.emit(code.OpImport, c.moduleInits[module])
+	c.emit(code.OpPop)
+	c.emit(code.OpConstant, c.addConstant(module))
+	c.setSymbol(c.symbolTable.Define(node.Name.Value))
+	return nil
+}
+
+// compileModule compiles the program of the file at path into a function
+// that runs it. The module's top-level bindings are globals of their own,
+// so its functions can use them after the function has returned.
+func (c *Compiler) compileModule(path string, program *ast.Program) object.Object {
+	outer := c.symbolTable
+	c.enterScope()
+	c.symbolTable = NewModuleSymbolTable(outer)
+	module := c.symbolTable
+
+	err := c.Compile(program)
+	c.emit(code.OpNull)
+	c.emit(code.OpReturnValue)
+
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+	c.symbolTable = outer
+
+	if err != nil {
+		compileErr := err.(*Error)
+		return &object.Error{Kind: object.RUNTIME_ERROR, Message: compileErr.Message, Pos: compileErr.Pos}
+	}
+
+	m := &object.Module{Path: path, Exports: map[string]bool{}, Globals: map[string]int{}}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			symbol, _ := module.Resolve(export.Statement.Name.Value)
+			m.Exports[symbol.Name] = true
+			m.Globals[symbol.Name] = symbol.Index
+		}
+	}
+
+	run := &object.CompiledFunction{Instructions: instructions, Positions: positions}
+	c.moduleInits[m] = c.addConstant(run)
+	return m
+}
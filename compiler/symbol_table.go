@@ -0,0 +1,181 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Const marks symbols declared with `const`.
+	Const bool
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+
+	// FreeSymbols holds the original symbols of the variables captured
+	// from enclosing scopes, in the order of their free indexes.
+	FreeSymbols []Symbol
+
+	// slots describes the slots of the table's scope. The global table of a
+	// program shares them with the tables of the modules the program
+	// imports, so each module's globals get slots of their own in the same
+	// store.
+	slots *slotTable
+
+	// blocks holds, for each block entered with EnterBlock, the symbols its
+	// definitions hide until it's left.
+	blocks []map[string]hiddenSymbol
+}
+
+type slotTable struct {
+	names  []string
+	consts []bool
+}
+
+type hiddenSymbol struct {
+	symbol Symbol
+	ok     bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s, FreeSymbols: []Symbol{}, slots: &slotTable{}}
+}
+
+// NewModuleSymbolTable returns the global table of a module imported by the
+// program that s belongs to.
+func NewModuleSymbolTable(s *SymbolTable) *SymbolTable {
+	module := NewSymbolTable()
+	module.slots = s.Global().slots
+	return module
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the symbol for name in this table's scope. Redefining a
+// name reuses its slot, the same way `let` overwrites a binding in an
+// object.Environment, unless the name was defined outside of the current
+// block.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := s.scope()
+
+	if len(s.blocks) > 0 {
+		block := s.blocks[len(s.blocks)-1]
+		if _, ok := block[name]; !ok {
+			symbol, ok := s.store[name]
+			block[name] = hiddenSymbol{symbol: symbol, ok: ok}
+			delete(s.store, name)
+		}
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Scope: scope, Index: len(s.slots.names)}
+	s.slots.names = append(s.slots.names, name)
+	s.slots.consts = append(s.slots.consts, false)
+
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineConst defines name like Define and marks it as constant.
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Const = true
+	s.store[name] = symbol
+	s.slots.consts[symbol.Index] = true
+	return symbol
+}
+
+// EnterBlock starts a block whose definitions get slots of their own, like
+// the bindings of an enclosed object.Environment, and are forgotten by
+// LeaveBlock.
+func (s *SymbolTable) EnterBlock() {
+	s.blocks = append(s.blocks, map[string]hiddenSymbol{})
+}
+
+// LeaveBlock ends the block started last, bringing back the symbols its
+// definitions hid.
+func (s *SymbolTable) LeaveBlock() {
+	block := s.blocks[len(s.blocks)-1]
+	s.blocks = s.blocks[:len(s.blocks)-1]
+
+	for name, hidden := range block {
+		if hidden.ok {
+			s.store[name] = hidden.symbol
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
+// IsConstInScope reports whether name is a constant defined in this table's
+// own scope.
+func (s *SymbolTable) IsConstInScope(name string) bool {
+	symbol, ok := s.store[name]
+	return ok && symbol.Scope == s.scope() && symbol.Const
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// Global returns the outermost symbol table.
+func (s *SymbolTable) Global() *SymbolTable {
+	if s.Outer == nil {
+		return s
+	}
+	return s.Outer.Global()
+}
+
+// Names returns the names defined in this table, indexed by slot. For a
+// global table, that includes the globals of the modules.
+func (s *SymbolTable) Names() []string {
+	return append([]string{}, s.slots.names...)
+}
+
+// ConstSlots reports, for each slot of this table, whether it holds a
+// constant.
+func (s *SymbolTable) ConstSlots() []bool {
+	return append([]bool{}, s.slots.consts...)
+}
+
+func (s *SymbolTable) scope() SymbolScope {
+	if s.Outer == nil {
+		return GlobalScope
+	}
+	return LocalScope
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1, Const: original.Const}
+	s.store[original.Name] = symbol
+	return symbol
+}
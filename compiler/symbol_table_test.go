@@ -0,0 +1,168 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("redefining a should reuse its slot. got=%+v", a)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+}
+
+func TestDefineConst(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineConst("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+
+	if global.IsConstInScope("a") || !global.IsConstInScope("b") {
+		t.Errorf("wrong constness of globals. got a=%t, b=%t", global.IsConstInScope("a"), global.IsConstInScope("b"))
+	}
+
+	if local.IsConstInScope("b") {
+		t.Errorf("b is not a constant of the local scope")
+	}
+
+	// A constant keeps its flag when it's captured as a free variable.
+	inner := NewEnclosedSymbolTable(local)
+	if b, ok := inner.Resolve("b"); !ok || !b.Const {
+		t.Errorf("expected b to resolve to a constant. got=%+v", b)
+	}
+
+	slots := global.ConstSlots()
+	if len(slots) != 2 || slots[0] || !slots[1] {
+		t.Errorf("wrong const slots. got=%v", slots)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	thirdLocal := NewEnclosedSymbolTable(secondLocal)
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: FreeScope, Index: 1},
+	}
+
+	for _, sym := range expected {
+		result, ok := thirdLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	// b is captured by the second function too, so the third can capture it from there.
+	expectedFree := []Symbol{
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for i, sym := range expectedFree {
+		if thirdLocal.FreeSymbols[i] != sym {
+			t.Errorf("wrong free symbol. expected=%+v, got=%+v", sym, thirdLocal.FreeSymbols[i])
+		}
+	}
+
+	if _, ok := thirdLocal.Resolve("d"); ok {
+		t.Errorf("name d resolved, but was expected not to")
+	}
+}
+
+func TestNames(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("x")
+	local.Resolve("a")
+
+	if names := global.Names(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("wrong global names. got=%v", names)
+	}
+	if names := local.Names(); len(names) != 1 || names[0] != "x" {
+		t.Errorf("wrong local names. got=%v", names)
+	}
+}
+
+func TestModuleSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	module := NewModuleSymbolTable(NewEnclosedSymbolTable(global))
+	x := module.DefineConst("x")
+	module.Define("a")
+
+	if x.Scope != GlobalScope || x.Index != 1 {
+		t.Errorf("wrong symbol for x. got=%+v", x)
+	}
+	if _, ok := global.Resolve("x"); ok {
+		t.Errorf("module global x resolved in the program")
+	}
+	if symbol, _ := global.Resolve("a"); symbol.Index != 0 {
+		t.Errorf("the module's a replaced the program's. got=%+v", symbol)
+	}
+
+	names := global.Names()
+	if len(names) != 3 || names[0] != "a" || names[1] != "x" || names[2] != "a" {
+		t.Errorf("wrong global names. got=%v", names)
+	}
+	if consts := global.ConstSlots(); !consts[1] || consts[0] || consts[2] {
+		t.Errorf("wrong const slots. got=%v", consts)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	global := NewSymbolTable()
+	outer := global.DefineConst("e")
+
+	global.EnterBlock()
+	inner := global.Define("e")
+	global.Define("x")
+	if inner.Index == outer.Index || inner.Const {
+		t.Errorf("e defined in the block reused the outer slot. got=%+v", inner)
+	}
+	global.LeaveBlock()
+
+	if symbol, _ := global.Resolve("e"); symbol != outer {
+		t.Errorf("e wasn't restored. got=%+v", symbol)
+	}
+	if _, ok := global.Resolve("x"); ok {
+		t.Errorf("x resolved after the block was left")
+	}
+}
@@ -0,0 +1,281 @@
+package core
+
+import (
+	"github.com/st0012/monkey/object"
+	"math"
+	"math/big"
+)
+
+// maxBits caps the size of the integers arithmetic makes, about a million
+// decimal digits, so asking for an enormous number is an error instead of
+// a program that hangs or runs out of memory. maxDigits is the same cap for
+// the digits of a decimal.
+const (
+	maxDigits = 1000000
+	maxBits   = maxDigits * 3322 / 1000
+)
+
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	if (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0) {
+		return 0, false
+	}
+	return sum, true
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	diff := a - b
+	if (a >= 0 && b < 0 && diff < 0) || (a < 0 && b > 0 && diff >= 0) {
+		return 0, false
+	}
+	return diff, true
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+// shiftInt64 shifts a left by a non-negative count.
+func shiftInt64(a, count int64) (int64, bool) {
+	if a == 0 {
+		return 0, true
+	}
+	if count >= 64 {
+		return 0, false
+	}
+
+	shifted := a << uint64(count)
+	if shifted>>uint64(count) != a {
+		return 0, false
+	}
+	return shifted, true
+}
+
+// powInt64 raises base to a non-negative exponent by squaring.
+func powInt64(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	ok := true
+
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = mulInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+
+		exponent >>= 1
+		if exponent > 0 {
+			if base, ok = mulInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return result, true
+}
+
+// integerOverflow is the result of an integer operation whose result
+// doesn't fit in an int64.
+func (r *Runtime) integerOverflow(left *object.Integer, operator string, right *object.Integer) object.Object {
+	if r.PromoteOnOverflow {
+		return evalBigIntInfixExpression(left, operator, right)
+	}
+	return NewError("integer overflow: %d %s %d", left.Value, operator, right.Value)
+}
+
+func evalBigIntInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+	leftValue := toBigInt(left)
+	rightValue := toBigInt(right)
+
+	switch operator {
+	case "+":
+		return normalizeBigInt(new(big.Int).Add(leftValue, rightValue))
+	case "-":
+		return normalizeBigInt(new(big.Int).Sub(leftValue, rightValue))
+	case "*":
+		if leftValue.BitLen()+rightValue.BitLen() > maxBits {
+			return tooLarge(left, operator, right)
+		}
+		return normalizeBigInt(new(big.Int).Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return normalizeBigInt(new(big.Int).Quo(leftValue, rightValue))
+	case "%":
+		if rightValue.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return normalizeBigInt(new(big.Int).Rem(leftValue, rightValue))
+	case "**":
+		if rightValue.Sign() < 0 {
+			return NewError("negative exponent: %s", rightValue)
+		}
+		// Only a base of 0, 1 or -1 keeps the power small, and the power
+		// of any other base has at least a bit per unit of the exponent.
+		if bits := int64(leftValue.BitLen() - 1); bits > 0 && (!rightValue.IsInt64() || rightValue.Int64() > maxBits/bits) {
+			return tooLarge(left, operator, right)
+		}
+		return normalizeBigInt(new(big.Int).Exp(leftValue, rightValue, nil))
+	case "&":
+		return normalizeBigInt(new(big.Int).And(leftValue, rightValue))
+	case "|":
+		return normalizeBigInt(new(big.Int).Or(leftValue, rightValue))
+	case "^":
+		return normalizeBigInt(new(big.Int).Xor(leftValue, rightValue))
+	case "<<":
+		if rightValue.Sign() < 0 {
+			return NewError("negative shift count: %s", rightValue)
+		}
+		if leftValue.Sign() != 0 && (!rightValue.IsInt64() || rightValue.Int64()+int64(leftValue.BitLen()) > maxBits) {
+			return tooLarge(left, operator, right)
+		}
+		return normalizeBigInt(new(big.Int).Lsh(leftValue, uint(rightValue.Uint64())))
+	case ">>":
+		if rightValue.Sign() < 0 {
+			return NewError("negative shift count: %s", rightValue)
+		}
+		// Shifting out every bit leaves 0, or -1 for a negative number.
+		count := uint(leftValue.BitLen())
+		if rightValue.IsInt64() && rightValue.Int64() < int64(count) {
+			count = uint(rightValue.Int64())
+		}
+		return normalizeBigInt(new(big.Int).Rsh(leftValue, count))
+	case "<":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) < 0}
+	case ">":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) > 0}
+	case "<=":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) <= 0}
+	case ">=":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) >= 0}
+	case "==":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) == 0}
+	case "!=":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) != 0}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// tooLarge is the error of an operation whose result would go over
+// maxBits or maxDigits.
+func tooLarge(left object.Object, operator string, right object.Object) *object.Error {
+	return NewError("number too large: %s %s %s", left.Inspect(), operator, right.Inspect())
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIG_INT_OBJ
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	}
+	return nil
+}
+
+// normalizeBigInt returns an Integer if value fits in one, so a BigInt
+// only ever holds values out of the int64 range.
+func normalizeBigInt(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+func evalFloatInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+	leftValue := toFloat64(left)
+	rightValue := toFloat64(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return NewError("division by zero")
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return NewError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case "<":
+		return &object.Boolean{Value: leftValue < rightValue}
+	case ">":
+		return &object.Boolean{Value: leftValue > rightValue}
+	case "<=":
+		return &object.Boolean{Value: leftValue <= rightValue}
+	case ">=":
+		return &object.Boolean{Value: leftValue >= rightValue}
+	case "==":
+		return &object.Boolean{Value: leftValue == rightValue}
+	case "!=":
+		return &object.Boolean{Value: leftValue != rightValue}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// isNumber reports whether obj is an Integer, a BigInt or a Float.
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat64(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+// floatToInteger converts a float with no fractional part to an integer.
+func (r *Runtime) floatToInteger(f float64) object.Object {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return NewError("cannot convert %s to INTEGER", (&object.Float{Value: f}).Inspect())
+	}
+
+	if f >= -(1<<63) && f < 1<<63 {
+		return &object.Integer{Value: int64(f)}
+	}
+
+	if !r.PromoteOnOverflow {
+		return NewError("integer overflow: %s", (&object.Float{Value: f}).Inspect())
+	}
+
+	value, _ := big.NewFloat(f).Int(nil)
+	return normalizeBigInt(value)
+}
+
+// BigInteger returns value as an Integer if it fits in one. Otherwise it's
+// an overflow, unless the PromoteOnOverflow option is set.
+func (r *Runtime) BigInteger(value *big.Int) object.Object {
+	if !value.IsInt64() && !r.PromoteOnOverflow {
+		return NewError("integer overflow: %s", value)
+	}
+	return normalizeBigInt(value)
+}
@@ -0,0 +1,346 @@
+package core
+
+import (
+	"fmt"
+	"github.com/st0012/monkey/object"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+)
+
+// builtins are the builtin functions. The compiler refers to a builtin by
+// its index in the list, so new builtins go at the end.
+var builtins = []struct {
+	name string
+	fn   func(r *Runtime, args ...object.Object) object.Object
+}{
+	{"len", (*Runtime).lenBuiltin},
+	{"puts", (*Runtime).putsBuiltin},
+	{"warn", (*Runtime).warnBuiltin},
+	{"first", (*Runtime).firstBuiltin},
+	{"last", (*Runtime).lastBuiltin},
+	{"rest", (*Runtime).restBuiltin},
+	{"push", (*Runtime).pushBuiltin},
+	{"type", (*Runtime).typeBuiltin},
+	{"int", (*Runtime).intBuiltin},
+	{"float", (*Runtime).floatBuiltin},
+	{"decimal", (*Runtime).decimalBuiltin},
+	{"round", (*Runtime).roundBuiltin},
+	{"error", (*Runtime).errorBuiltin},
+	{"floor", (*Runtime).floorBuiltin},
+	{"ceil", (*Runtime).ceilBuiltin},
+}
+
+func (r *Runtime) lenBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Keys))}
+	default:
+		return NewError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+func (r *Runtime) firstBuiltin(args ...object.Object) object.Object {
+	array, err := arrayArgument("first", args)
+	if err != nil {
+		return err
+	}
+
+	if len(array.Elements) > 0 {
+		return array.Elements[0]
+	}
+
+	return NULL
+}
+
+func (r *Runtime) lastBuiltin(args ...object.Object) object.Object {
+	array, err := arrayArgument("last", args)
+	if err != nil {
+		return err
+	}
+
+	length := len(array.Elements)
+	if length > 0 {
+		return array.Elements[length-1]
+	}
+
+	return NULL
+}
+
+func (r *Runtime) restBuiltin(args ...object.Object) object.Object {
+	array, err := arrayArgument("rest", args)
+	if err != nil {
+		return err
+	}
+
+	length := len(array.Elements)
+	if length > 0 {
+		newElements := make([]object.Object, length-1)
+		copy(newElements, array.Elements[1:length])
+		return &object.Array{Elements: newElements}
+	}
+
+	return NULL
+}
+
+func (r *Runtime) pushBuiltin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongNumberOfArguments(len(args), 2)
+	}
+
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return NewError("argument to `push` must be ARRAY, got %s", args[0].Type())
+	}
+
+	length := len(array.Elements)
+	newElements := make([]object.Object, length+1)
+	copy(newElements, array.Elements)
+	newElements[length] = args[1]
+
+	return &object.Array{Elements: newElements}
+}
+
+func (r *Runtime) typeBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(len(args), 1)
+	}
+
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// intBuiltin converts a number or a numeric string to an integer. Floats
+// and decimals are truncated towards zero.
+func (r *Runtime) intBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Float:
+		return r.floatToInteger(math.Trunc(arg.Value))
+	case *object.Decimal:
+		return r.BigInteger(roundQuotient(arg.Value, pow10(arg.Scale), RoundDown))
+	case *object.String:
+		value, ok := new(big.Int).SetString(arg.Value, 10)
+		if !ok {
+			return NewError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		return r.BigInteger(value)
+	default:
+		return NewError("argument to `int` not supported, got %s", args[0].Type())
+	}
+}
+
+func (r *Runtime) floatBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt, *object.Float:
+		return &object.Float{Value: toFloat64(arg)}
+	case *object.Decimal:
+		value, _ := new(big.Rat).SetFrac(arg.Value, pow10(arg.Scale)).Float64()
+		return &object.Float{Value: value}
+	case *object.String:
+		value, err := strconv.ParseFloat(arg.Value, 64)
+		if err != nil {
+			return NewError("cannot convert %s to FLOAT", arg.Inspect())
+		}
+		return &object.Float{Value: value}
+	default:
+		return NewError("argument to `float` not supported, got %s", args[0].Type())
+	}
+}
+
+func (r *Runtime) decimalBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt, *object.Decimal:
+		return toDecimal(arg)
+	case *object.Float:
+		value, ok := decimalFromFloat(arg.Value)
+		if !ok {
+			return NewError("cannot convert %s to DECIMAL", arg.Inspect())
+		}
+		return value
+	case *object.String:
+		value, ok := object.ParseDecimal(arg.Value)
+		if !ok {
+			return NewError("cannot convert %s to DECIMAL", arg.Inspect())
+		}
+		return value
+	default:
+		return NewError("argument to `decimal` not supported, got %s", args[0].Type())
+	}
+}
+
+// roundBuiltin rounds a float half away from zero, and a decimal with the
+// DecimalRounding option. With a number of digits it returns a number of
+// the same type rounded to that many decimal places, otherwise an integer.
+func (r *Runtime) roundBuiltin(args ...object.Object) object.Object {
+	switch {
+	case len(args) == 0:
+		return wrongNumberOfArguments(len(args), 1)
+	case len(args) > 2:
+		return wrongNumberOfArguments(len(args), 2)
+	}
+
+	if len(args) == 2 {
+		digits, ok := args[1].(*object.Integer)
+		if !ok {
+			return NewError("argument to `round` must be INTEGER, got %s", args[1].Type())
+		}
+
+		switch arg := args[0].(type) {
+		case *object.Float:
+			scale := math.Pow(10, float64(digits.Value))
+			return &object.Float{Value: math.Round(arg.Value*scale) / scale}
+		case *object.Decimal:
+			if digits.Value < 0 {
+				return NewError("number of digits must not be negative, got %d", digits.Value)
+			}
+			if digits.Value > maxDigits {
+				return NewError("number of digits must be at most %d, got %d", maxDigits, digits.Value)
+			}
+			return roundDecimal(arg, int(digits.Value), r.DecimalRounding)
+		}
+	}
+
+	return r.roundTo("round", math.Round, r.DecimalRounding, args[:1])
+}
+
+func (r *Runtime) floorBuiltin(args ...object.Object) object.Object {
+	return r.roundTo("floor", math.Floor, RoundFloor, args)
+}
+
+func (r *Runtime) ceilBuiltin(args ...object.Object) object.Object {
+	return r.roundTo("ceil", math.Ceil, RoundCeiling, args)
+}
+
+// roundTo is a builtin that rounds a float to an integer with the given
+// function, and a decimal with the given mode. Integers are returned as
+// they are.
+func (r *Runtime) roundTo(name string, round func(float64) float64, mode RoundingMode, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongNumberOfArguments(len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Float:
+		return r.floatToInteger(round(arg.Value))
+	case *object.Decimal:
+		return r.BigInteger(roundQuotient(arg.Value, pow10(arg.Scale), mode))
+	default:
+		return NewError("argument to `%s` not supported, got %s", name, args[0].Type())
+	}
+}
+
+// errorBuiltin makes an error value with a message and an optional kind,
+// to be thrown.
+func (r *Runtime) errorBuiltin(args ...object.Object) object.Object {
+	switch {
+	case len(args) == 0:
+		return wrongNumberOfArguments(len(args), 1)
+	case len(args) > 2:
+		return wrongNumberOfArguments(len(args), 2)
+	}
+
+	message, ok := args[0].(*object.String)
+	if !ok {
+		return NewError("argument to `error` must be STRING, got %s", args[0].Type())
+	}
+
+	kind := object.THROWN_ERROR
+	if len(args) == 2 {
+		k, ok := args[1].(*object.String)
+		if !ok {
+			return NewError("argument to `error` must be STRING, got %s", args[1].Type())
+		}
+		kind = k.Value
+	}
+
+	return &object.ErrorValue{Kind: kind, Message: message.Value}
+}
+
+func (r *Runtime) putsBuiltin(args ...object.Object) object.Object {
+	return printArguments(r.Stdout, args)
+}
+
+func (r *Runtime) warnBuiltin(args ...object.Object) object.Object {
+	return printArguments(r.Stderr, args)
+}
+
+// printArguments prints each argument on its own line to out. Strings are
+// printed without the quotes Inspect adds.
+func printArguments(out io.Writer, args []object.Object) object.Object {
+	for _, arg := range args {
+		if str, ok := arg.(*object.String); ok {
+			fmt.Fprintln(out, str.Value)
+		} else {
+			fmt.Fprintln(out, arg.Inspect())
+		}
+	}
+
+	return NULL
+}
+
+// LookupBuiltin returns the builtin function with the given name.
+func (r *Runtime) LookupBuiltin(name string) (*object.Builtin, bool) {
+	if i, ok := BuiltinIndex(name); ok {
+		return r.builtins[i], true
+	}
+	return nil, false
+}
+
+// Builtin returns the builtin function with the given index.
+func (r *Runtime) Builtin(index int) *object.Builtin {
+	return r.builtins[index]
+}
+
+// BuiltinIndex returns the index of the builtin with the given name, which
+// the compiler refers to it by.
+func BuiltinIndex(name string) (int, bool) {
+	for i, b := range builtins {
+		if b.name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// arrayArgument checks that a builtin got exactly one array argument.
+func arrayArgument(name string, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) != 1 {
+		return nil, wrongNumberOfArguments(len(args), 1)
+	}
+
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, NewError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	return array, nil
+}
+
+func wrongNumberOfArguments(got, want int) *object.Error {
+	return NewError("wrong arguments: expect=%d, got=%d", want, got)
+}
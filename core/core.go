@@ -0,0 +1,78 @@
+// Package core implements what the evaluator and the vm have in common:
+// the operators, conditions, iteration and builtin functions of the
+// language. Both engines call into it, so a program means the same thing
+// whichever engine runs it.
+package core
+
+import (
+	"fmt"
+	"github.com/st0012/monkey/object"
+)
+
+var (
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+	NULL  = &object.Null{}
+)
+
+// MaxCallDepth is how deeply function calls can nest. A call past it fails
+// with a "stack overflow" error in both engines, which a program can catch.
+const MaxCallDepth = 10000
+
+// NewError returns a runtime error with a formatted message.
+func NewError(format string, args ...interface{}) *object.Error {
+	return &object.Error{Kind: object.RUNTIME_ERROR, Message: fmt.Sprintf(format, args...)}
+}
+
+// Throw returns the error a throw statement raises with val. An error value
+// is raised again as it was caught, trace included, and a string becomes
+// the message of an error.
+func Throw(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.ErrorValue:
+		trace := append([]object.Frame{}, val.Trace...)
+		return &object.Error{Kind: val.Kind, Message: val.Message, Pos: val.Pos, Trace: trace}
+	case *object.String:
+		return &object.Error{Kind: object.THROWN_ERROR, Message: val.Value}
+	default:
+		return NewError("cannot throw %s", val.Type())
+	}
+}
+
+// Catch returns err as the error value a catch block gets.
+func Catch(err *object.Error) *object.ErrorValue {
+	return &object.ErrorValue{Kind: err.Kind, Message: err.Message, Pos: err.Pos, Trace: err.Trace}
+}
+
+// Condition reports whether condition counts as true, following the
+// object.Truthy protocol.
+func (r *Runtime) Condition(condition object.Object) (bool, *object.Error) {
+	if r.StrictConditions && condition.Type() != object.BOOLEAN_OBJ {
+		return false, NewError("condition must be BOOLEAN, got %s", condition.Type())
+	}
+
+	return object.IsTruthy(condition), nil
+}
+
+// Iterate returns the values a for loop walks through: the elements of an
+// array, the characters of a string or the keys of a hash.
+func Iterate(obj object.Object) ([]object.Object, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return append([]object.Object{}, obj.Elements...), nil
+	case *object.String:
+		elements := []object.Object{}
+		for _, r := range obj.Value {
+			elements = append(elements, &object.String{Value: string(r)})
+		}
+		return elements, nil
+	case *object.Hash:
+		elements := []object.Object{}
+		for _, key := range obj.Keys {
+			elements = append(elements, obj.Pairs[key].Key)
+		}
+		return elements, nil
+	default:
+		return nil, NewError("cannot iterate over %s", obj.Type())
+	}
+}
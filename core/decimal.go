@@ -0,0 +1,224 @@
+package core
+
+import (
+	"github.com/st0012/monkey/object"
+	"math/big"
+	"strconv"
+)
+
+// RoundingMode decides which way a decimal goes when digits are dropped.
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota
+	RoundHalfUp
+	RoundHalfDown
+	RoundUp
+	RoundDown
+	RoundCeiling
+	RoundFloor
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half_even": RoundHalfEven,
+	"half_up":   RoundHalfUp,
+	"half_down": RoundHalfDown,
+	"up":        RoundUp,
+	"down":      RoundDown,
+	"ceiling":   RoundCeiling,
+	"floor":     RoundFloor,
+}
+
+// ParseRoundingMode returns the rounding mode with the given name, like
+// "half_even" or "floor".
+func ParseRoundingMode(name string) (RoundingMode, bool) {
+	mode, ok := roundingModes[name]
+	return mode, ok
+}
+
+func (r *Runtime) evalDecimalInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+	leftDecimal := toDecimal(left)
+	rightDecimal := toDecimal(right)
+
+	scale := max(leftDecimal.Scale, rightDecimal.Scale)
+	leftValue := rescale(leftDecimal, scale)
+	rightValue := rescale(rightDecimal, scale)
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Value: new(big.Int).Add(leftValue, rightValue), Scale: scale}
+	case "-":
+		return &object.Decimal{Value: new(big.Int).Sub(leftValue, rightValue), Scale: scale}
+	case "*":
+		if leftDecimal.Value.BitLen()+rightDecimal.Value.BitLen() > maxBits || leftDecimal.Scale+rightDecimal.Scale > maxDigits {
+			return tooLarge(left, operator, right)
+		}
+		value := new(big.Int).Mul(leftDecimal.Value, rightDecimal.Value)
+		return &object.Decimal{Value: value, Scale: leftDecimal.Scale + rightDecimal.Scale}
+	case "/":
+		if rightValue.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return r.divideDecimal(leftDecimal, rightDecimal)
+	case "%":
+		if rightValue.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return &object.Decimal{Value: new(big.Int).Rem(leftValue, rightValue), Scale: scale}
+	case "**":
+		exponent, ok := right.(*object.Integer)
+		if !ok {
+			return NewError("exponent of a DECIMAL must be INTEGER, got %s", right.Type())
+		}
+		return r.powDecimal(leftDecimal, exponent.Value)
+	case "<":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) < 0}
+	case ">":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) > 0}
+	case "<=":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) <= 0}
+	case ">=":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) >= 0}
+	case "==":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) == 0}
+	case "!=":
+		return &object.Boolean{Value: leftValue.Cmp(rightValue) != 0}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// divideDecimal keeps DecimalDivisionScale decimal places of the quotient,
+// then drops the trailing zeros down to the larger scale of the operands,
+// so `10.00d / 4` is `2.50d` and not `2.5000000000000000d`.
+func (r *Runtime) divideDecimal(left, right *object.Decimal) object.Object {
+	scale := max(r.DecimalDivisionScale, left.Scale, right.Scale)
+	numerator := new(big.Int).Mul(left.Value, pow10(scale+right.Scale-left.Scale))
+	value := roundQuotient(numerator, right.Value, r.DecimalRounding)
+
+	preferred := max(left.Scale, right.Scale)
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	for scale > preferred {
+		quotient, _ := new(big.Int).QuoRem(value, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		value = quotient
+		scale--
+	}
+
+	return &object.Decimal{Value: value, Scale: scale}
+}
+
+func (r *Runtime) powDecimal(base *object.Decimal, exponent int64) object.Object {
+	if exponent < 0 && base.Value.Sign() == 0 {
+		return NewError("division by zero")
+	}
+
+	magnitude := uint64(exponent)
+	if exponent < 0 {
+		magnitude = -magnitude
+	}
+
+	// Both the digits and the scale of the power grow with the exponent.
+	bits := base.Value.BitLen() - 1
+	if (bits > 0 && magnitude > uint64(maxBits/bits)) || (base.Scale > 0 && magnitude > uint64(maxDigits/base.Scale)) {
+		return tooLarge(base, "**", &object.Integer{Value: exponent})
+	}
+
+	value := new(big.Int).Exp(base.Value, new(big.Int).SetUint64(magnitude), nil)
+	power := &object.Decimal{Value: value, Scale: base.Scale * int(magnitude)}
+
+	if exponent < 0 {
+		return r.divideDecimal(&object.Decimal{Value: big.NewInt(1)}, power)
+	}
+	return power
+}
+
+// roundDecimal rounds d to the given number of decimal places.
+func roundDecimal(d *object.Decimal, scale int, mode RoundingMode) *object.Decimal {
+	if scale >= d.Scale {
+		return &object.Decimal{Value: rescale(d, scale), Scale: scale}
+	}
+
+	value := roundQuotient(d.Value, pow10(d.Scale-scale), mode)
+	return &object.Decimal{Value: value, Scale: scale}
+}
+
+// roundQuotient divides x by y and rounds the quotient to an integer.
+func roundQuotient(x, y *big.Int, mode RoundingMode) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(x, y, new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	// The sign of the exact quotient, and how the dropped part compares
+	// to a half.
+	sign := x.Sign() * y.Sign()
+	half := new(big.Int).Abs(remainder)
+	half.Lsh(half, 1)
+	cmp := half.Cmp(new(big.Int).Abs(y))
+
+	var awayFromZero bool
+	switch mode {
+	case RoundHalfEven:
+		awayFromZero = cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1)
+	case RoundHalfUp:
+		awayFromZero = cmp >= 0
+	case RoundHalfDown:
+		awayFromZero = cmp > 0
+	case RoundUp:
+		awayFromZero = true
+	case RoundDown:
+		awayFromZero = false
+	case RoundCeiling:
+		awayFromZero = sign > 0
+	case RoundFloor:
+		awayFromZero = sign < 0
+	}
+
+	if awayFromZero {
+		quotient.Add(quotient, big.NewInt(int64(sign)))
+	}
+
+	return quotient
+}
+
+// isDecimalOperation reports whether left and right are both exact numbers
+// and at least one of them is a decimal. Integers mix with decimals, but
+// floats don't, since that would bring binary rounding back in.
+func isDecimalOperation(left, right object.Object) bool {
+	if left.Type() != object.DECIMAL_OBJ && right.Type() != object.DECIMAL_OBJ {
+		return false
+	}
+
+	return (isInteger(left) || left.Type() == object.DECIMAL_OBJ) &&
+		(isInteger(right) || right.Type() == object.DECIMAL_OBJ)
+}
+
+func toDecimal(obj object.Object) *object.Decimal {
+	if d, ok := obj.(*object.Decimal); ok {
+		return d
+	}
+	return &object.Decimal{Value: toBigInt(obj)}
+}
+
+// decimalFromFloat converts f through its shortest decimal representation,
+// so `0.1` becomes `0.1d`.
+func decimalFromFloat(f float64) (*object.Decimal, bool) {
+	return object.ParseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// rescale returns the unscaled value of d at a scale that isn't smaller
+// than its own.
+func rescale(d *object.Decimal, scale int) *big.Int {
+	if scale == d.Scale {
+		return d.Value
+	}
+	return new(big.Int).Mul(d.Value, pow10(scale-d.Scale))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
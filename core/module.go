@@ -0,0 +1,125 @@
+package core
+
+import (
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/lexer"
+	"github.com/st0012/monkey/object"
+	"github.com/st0012/monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Loader finds and parses the files a program imports, and caches the
+// modules made from them by absolute path, so a file is loaded once however
+// many files import it. Each interpreter or compilation has a loader of its
+// own, so nothing is shared between programs.
+type Loader struct {
+	// searchPaths are the directories an import is looked up in when the
+	// file isn't next to the file that imports it.
+	searchPaths []string
+
+	modules map[string]object.Object
+
+	// loading holds the files whose imports are being loaded, outermost
+	// first, so an import cycle can be reported instead of recursing forever.
+	loading []loadingModule
+}
+
+type loadingModule struct {
+	path string
+	abs  string
+}
+
+func NewLoader(searchPaths []string) *Loader {
+	return &Loader{searchPaths: searchPaths, modules: map[string]object.Object{}}
+}
+
+// Import returns the module at path. If no file imported it before, the
+// file is parsed and load makes the module out of it. importer is the file
+// with the import, or "" outside of a file.
+func (l *Loader) Import(path, importer string, load func(path string, program *ast.Program) object.Object) object.Object {
+	resolved, ok := l.resolve(path, filepath.Dir(importer))
+	if !ok {
+		return NewError("module not found: %s", path)
+	}
+
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return NewError("could not load module %s: %s", path, err)
+	}
+
+	if module, ok := l.modules[abs]; ok {
+		return module
+	}
+
+	// The file that starts the imports isn't a module itself, but a module
+	// that imports it back is still a cycle.
+	if len(l.loading) == 0 && importer != "" {
+		if root, err := filepath.Abs(importer); err == nil {
+			l.loading = append(l.loading, loadingModule{path: importer, abs: root})
+			defer func() { l.loading = nil }()
+		}
+	}
+
+	for i, m := range l.loading {
+		if m.abs == abs {
+			cycle := []string{}
+			for _, m := range l.loading[i:] {
+				cycle = append(cycle, m.path)
+			}
+			cycle = append(cycle, resolved)
+
+			return NewError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	program, parseErr := parseModule(resolved)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	l.loading = append(l.loading, loadingModule{path: resolved, abs: abs})
+	module := load(resolved, program)
+	l.loading = l.loading[:len(l.loading)-1]
+
+	if module.Type() == object.ERROR_OBJ {
+		return module
+	}
+
+	l.modules[abs] = module
+	return module
+}
+
+// resolve finds the file an import refers to. A relative path is looked up
+// next to the importing file first, then in the search paths.
+func (l *Loader) resolve(path, dir string) (string, bool) {
+	dirs := []string{""}
+	if !filepath.IsAbs(path) {
+		dirs = append([]string{dir}, l.searchPaths...)
+	}
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+func parseModule(path string) (*ast.Program, *object.Error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewError("could not load module %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.NewWithFilename(string(src), path))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, NewError("could not parse module %s:\n%s", path, strings.Join(p.Errors(), "\n"))
+	}
+
+	return program, nil
+}
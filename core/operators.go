@@ -0,0 +1,278 @@
+package core
+
+import (
+	"github.com/st0012/monkey/object"
+	"math"
+	"math/big"
+)
+
+// Prefix applies a prefix operator to an evaluated operand.
+func (r *Runtime) Prefix(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return r.evalBangPrefixExpression(right)
+	case "-":
+		return r.evalMinusPrefixExpression(right)
+	case "~":
+		return evalTildePrefixExpression(right)
+	}
+	return NewError("unknown operator: %s%s", operator, right.Type())
+}
+
+func (r *Runtime) evalBangPrefixExpression(right object.Object) object.Object {
+	truthy, err := r.Condition(right)
+	if err != nil {
+		return err
+	}
+
+	if truthy {
+		return FALSE
+	}
+	return TRUE
+}
+
+func (r *Runtime) evalMinusPrefixExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			if r.PromoteOnOverflow {
+				return normalizeBigInt(new(big.Int).Neg(toBigInt(right)))
+			}
+			return NewError("integer overflow: -(%d)", right.Value)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return normalizeBigInt(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	case *object.Decimal:
+		return &object.Decimal{Value: new(big.Int).Neg(right.Value), Scale: right.Scale}
+	default:
+		return NewError("unknown operator: %s%s", "-", right.Type())
+	}
+}
+
+func evalTildePrefixExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return normalizeBigInt(new(big.Int).Not(right.Value))
+	default:
+		return NewError("unknown operator: %s%s", "~", right.Type())
+	}
+}
+
+// Infix applies an infix operator to evaluated operands. `&&` and `||` are
+// not handled here, because they don't always evaluate their right operand.
+func (r *Runtime) Infix(left object.Object, operator string, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return r.evalIntegerInfixExpression(left, operator, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntInfixExpression(left, operator, right)
+	case isDecimalOperation(left, right):
+		return r.evalDecimalInfixExpression(left, operator, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(left, operator, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(left, operator, right)
+	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
+		return evalBooleanInfixExpression(left, operator, right)
+	default:
+		return NewError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func (r *Runtime) evalIntegerInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	var result int64
+	ok := true
+
+	switch operator {
+	case "+":
+		result, ok = addInt64(leftValue, rightValue)
+	case "-":
+		result, ok = subInt64(leftValue, rightValue)
+	case "*":
+		result, ok = mulInt64(leftValue, rightValue)
+	case "/":
+		if rightValue == 0 {
+			return NewError("division by zero")
+		}
+		// The only quotient out of range is math.MinInt64 / -1.
+		result, ok = leftValue/rightValue, !(leftValue == math.MinInt64 && rightValue == -1)
+	case "%":
+		if rightValue == 0 {
+			return NewError("division by zero")
+		}
+		result = leftValue % rightValue
+	case "**":
+		if rightValue < 0 {
+			return NewError("negative exponent: %d", rightValue)
+		}
+		result, ok = powInt64(leftValue, rightValue)
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<", ">>":
+		if rightValue < 0 {
+			return NewError("negative shift count: %d", rightValue)
+		}
+		if operator == "<<" {
+			result, ok = shiftInt64(leftValue, rightValue)
+			break
+		}
+		return &object.Integer{Value: leftValue >> uint64(rightValue)}
+	case ">":
+		return &object.Boolean{Value: leftValue > rightValue}
+	case "<":
+		return &object.Boolean{Value: leftValue < rightValue}
+	case ">=":
+		return &object.Boolean{Value: leftValue >= rightValue}
+	case "<=":
+		return &object.Boolean{Value: leftValue <= rightValue}
+	case "==":
+		return &object.Boolean{Value: leftValue == rightValue}
+	case "!=":
+		return &object.Boolean{Value: leftValue != rightValue}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	if !ok {
+		return r.integerOverflow(left.(*object.Integer), operator, right.(*object.Integer))
+	}
+	return &object.Integer{Value: result}
+}
+
+func evalStringInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return &object.Boolean{Value: leftValue == rightValue}
+	case "!=":
+		return &object.Boolean{Value: leftValue != rightValue}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalBooleanInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+	leftValue := left.(*object.Boolean).Value
+	rightValue := right.(*object.Boolean).Value
+	switch operator {
+	case "==":
+		return &object.Boolean{Value: leftValue == rightValue}
+	case "!=":
+		return &object.Boolean{Value: leftValue != rightValue}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+}
+
+// Index reads an element of an array or hash, or a field of an error value.
+func Index(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorValueIndexExpression(left, index)
+	default:
+		return NewError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// evalErrorValueIndexExpression reads the "kind", "message" or "position"
+// field of an error value.
+func evalErrorValueIndexExpression(errorValue object.Object, index object.Object) object.Object {
+	ev := errorValue.(*object.ErrorValue)
+
+	switch index.(*object.String).Value {
+	case "kind":
+		return &object.String{Value: ev.Kind}
+	case "message":
+		return &object.String{Value: ev.Message}
+	case "position":
+		if !ev.Pos.IsValid() {
+			return NULL
+		}
+		return &object.String{Value: ev.Pos.String()}
+	default:
+		return NULL
+	}
+}
+
+func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+	length := int64(len(elements))
+
+	// Negative indexes count from the end of the array.
+	if idx < 0 {
+		idx += length
+	}
+
+	if idx < 0 || idx >= length {
+		return NewError("index out of range: %d (length %d)", index.(*object.Integer).Value, length)
+	}
+
+	return elements[idx]
+}
+
+func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return NewError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hash.(*object.Hash).Get(key)
+	if !ok {
+		return NULL
+	}
+
+	return value
+}
+
+// SetIndex stores value at the index of an array or hash.
+func SetIndex(left object.Object, index object.Object, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		idx := index.(*object.Integer).Value
+		length := int64(len(elements))
+
+		if idx < 0 {
+			idx += length
+		}
+
+		if idx < 0 || idx >= length {
+			return NewError("index out of range: %d (length %d)", index.(*object.Integer).Value, length)
+		}
+
+		elements[idx] = value
+		return value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return NewError("unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Set(key, value)
+		return value
+	default:
+		return NewError("index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"github.com/st0012/monkey/object"
+	"io"
+	"os"
+)
+
+// Options change how a Runtime runs programs.
+type Options struct {
+	// PromoteOnOverflow makes integer arithmetic that overflows int64
+	// produce a BigInt. When it's off, overflowing is an error.
+	PromoteOnOverflow bool
+	// DecimalRounding is the rounding mode of decimal division and of
+	// `round` when it's given a decimal.
+	DecimalRounding RoundingMode
+	// DecimalDivisionScale is the number of decimal places kept when the
+	// quotient of a decimal division doesn't terminate.
+	DecimalDivisionScale int
+	// StrictConditions makes a condition of if, while, `!`, `&&` or `||`
+	// that isn't a boolean an error, instead of going by its truthiness.
+	StrictConditions bool
+	// Stdout and Stderr are where `puts` and `warn` write to.
+	Stdout io.Writer
+	Stderr io.Writer
+	// ModulePaths are the directories an import is looked up in when the
+	// file isn't next to the file that imports it.
+	ModulePaths []string
+}
+
+// DefaultOptions returns the options programs run with unless they're told
+// otherwise.
+func DefaultOptions() Options {
+	return Options{
+		PromoteOnOverflow:    true,
+		DecimalRounding:      RoundHalfEven,
+		DecimalDivisionScale: 16,
+		Stdout:               os.Stdout,
+		Stderr:               os.Stderr,
+	}
+}
+
+// Runtime holds the options of one interpreter. The operators and builtins
+// that depend on them are its methods, so interpreters with different
+// options can run side by side.
+type Runtime struct {
+	Options
+
+	builtins []*object.Builtin
+}
+
+func New(options Options) *Runtime {
+	r := &Runtime{Options: options}
+
+	r.builtins = make([]*object.Builtin, len(builtins))
+	for i, b := range builtins {
+		fn := b.fn
+		r.builtins[i] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return fn(r, args...)
+		}}
+	}
+
+	return r
+}
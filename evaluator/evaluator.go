@@ -3,79 +3,190 @@ package evaluator
 import (
 	"fmt"
 	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/core"
 	"github.com/st0012/monkey/object"
+	"github.com/st0012/monkey/token"
+	"strings"
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE  = core.TRUE
+	FALSE = core.FALSE
+	NULL  = core.NULL
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Evaluator walks the syntax tree, running programs with the operators and
+// builtins of its runtime.
+type Evaluator struct {
+	rt     *core.Runtime
+	loader *core.Loader
+
+	// depth counts the calls of user functions that are running.
+	depth int
+}
+
+func New(rt *core.Runtime) *Evaluator {
+	return &Evaluator{rt: rt, loader: core.NewLoader(rt.ModulePaths)}
+}
+
+// Eval evaluates node with the default options.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(core.New(core.DefaultOptions())).Eval(node, env)
+}
+
+// Eval evaluates the node and, if it results in an error that has no
+// position yet, tags the error with the node's position.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	result := e.eval(node, env)
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatements(node.Statements, env)
+		return e.evalBlockStatements(node.Statements, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		if env.IsConstInScope(node.Name.Value) {
+			return newError("cannot redeclare constant: %s", node.Name.Value)
+		}
+
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
+
+		if node.IsConst() {
+			return env.SetConst(node.Name.Value, val)
+		}
 		return env.Set(node.Name.Value, val)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.ThrowStatement:
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return core.Throw(val)
+	case *ast.TryStatement:
+		return e.evalTryStatement(node, env)
+	case *ast.ImportStatement:
+		return e.evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return e.Eval(node.Statement, env)
 	case *ast.Identifier:
-		val, exist := env.Get(node.Value)
-		if !exist {
-			return newError("identifier not found: %s", node.Value)
+		if val, exist := env.Get(node.Value); exist {
+			return val
 		}
-		return val
+		if builtin, exist := e.rt.LookupBuiltin(node.Value); exist {
+			return builtin
+		}
+		return newError("identifier not found: %s", node.Value)
 
 	// Expressions
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.FunctionExpression:
-		return &object.Function{Parameters: node.Parameters, Body: node.BlockStatement, Env: env}
+		return &object.Function{Name: node.Name, Parameters: node.Parameters, Body: node.BlockStatement, Env: env}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		function := e.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalArgs(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
 
-		return applyFunction(function, args)
+		return e.applyFunction(function, args, node.Pos())
 
 	case *ast.PrefixExpression:
-		val := Eval(node.Right, env)
+		val := e.Eval(node.Right, env)
 		if isError(val) {
 			return val
 		}
-		return evalPrefixExpression(node.Operator, val)
+		return e.rt.Prefix(node.Operator, val)
 	case *ast.InfixExpression:
-		valLeft := Eval(node.Left, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+
+		valLeft := e.Eval(node.Left, env)
 		if isError(valLeft) {
 			return valLeft
 		}
 
-		valRight := Eval(node.Right, env)
+		valRight := e.Eval(node.Right, env)
 		if isError(valRight) {
 			return valRight
 		}
 
-		return evalInfixExpression(valLeft, node.Operator, valRight)
+		return e.rt.Infix(valLeft, node.Operator, valRight)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return e.rt.BigInteger(node.Value)
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value, Scale: node.Scale}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
+	case *ast.IndexExpression:
+		left := e.Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := e.Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		return core.Index(left, index)
+	case *ast.MemberExpression:
+		obj := e.Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+
+		return evalMemberExpression(obj, node.Member.Value)
 	case *ast.Boolean:
 		if node.Value {
 			return TRUE
@@ -86,11 +197,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -103,15 +214,15 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
 			switch result := result.(type) {
-			case *object.ReturnValue:
+			case *object.ReturnValue, *object.Break, *object.Continue:
 				return result
 			case *object.Error:
 				return result
@@ -122,115 +233,265 @@ func evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.
 	return result
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
-	switch operator {
-	case "!":
-		return evalBangPrefixExpression(right)
-	case "-":
-		return evalMinusPrefixExpression(right)
+// evalLogicalExpression evaluates `&&` and `||`. The right operand is only
+// evaluated if the left one doesn't decide the result, and the value of the
+// deciding operand is returned as is.
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
 	}
-	return newError("unknown operator: %s%s", operator, right.Type())
-}
 
-func evalBangPrefixExpression(right object.Object) *object.Boolean {
-	switch right {
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
+	truthy, err := e.rt.Condition(left)
+	if err != nil {
+		return err
 	}
-}
 
-func evalMinusPrefixExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: %s%s", "-", right.Type())
+	if truthy == (node.Operator == "||") {
+		return left
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
-}
 
-func evalInfixExpression(left object.Object, operator string, right object.Object) object.Object {
-	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(left, operator, right)
-	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
-		return evalBooleanInfixExpression(left, operator, right)
-	default:
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
-	}
+	return e.Eval(node.Right, env)
 }
 
-func evalIntegerInfixExpression(left object.Object, operator string, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
-	switch operator {
-	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
-	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
-	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
-	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
-	case ">":
-		return &object.Boolean{Value: leftValue > rightValue}
-	case "<":
-		return &object.Boolean{Value: leftValue < rightValue}
-	case "==":
-		return &object.Boolean{Value: leftValue == rightValue}
-	case "!=":
-		return &object.Boolean{Value: leftValue != rightValue}
+// evalAssignExpression evaluates `target = value` or a compound assignment
+// like `target += value`, which applies the operator to the current value.
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		current, ok := env.Get(target.Value)
+		if !ok {
+			return newError("identifier not found: %s", target.Value)
+		}
+
+		if env.IsConst(target.Value) {
+			return newError("cannot assign to constant: %s", target.Value)
+		}
+
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if operator != "" {
+			val = e.rt.Infix(current, operator, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		env.Assign(target.Value, val)
+		return val
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := e.Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if operator != "" {
+			current = core.Index(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if operator != "" {
+			val = e.rt.Infix(current, operator, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		return core.SetIndex(left, index, val)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("invalid assignment target: %s", node.Target.String())
 	}
 }
 
-func evalBooleanInfixExpression(left object.Object, operator string, right object.Object) object.Object {
-	leftValue := left.(*object.Boolean).Value
-	rightValue := right.(*object.Boolean).Value
-	switch operator {
-	case "==":
-		return &object.Boolean{Value: leftValue == rightValue}
-	case "!=":
-		return &object.Boolean{Value: leftValue != rightValue}
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := e.Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := e.Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
 	}
 
+	return hash
 }
 
-func evalIfExpression(exp *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(exp.Condition, env)
+func (e *Evaluator) evalIfExpression(exp *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(exp.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
-	if condition.Type() == object.INTEGER_OBJ || condition.(*object.Boolean).Value {
-		return Eval(exp.Consequence, env)
+	truthy, err := e.rt.Condition(condition)
+	if err != nil {
+		return err
+	}
+
+	if truthy {
+		return e.Eval(exp.Consequence, env)
 	} else {
 		if exp.Alternative != nil {
-			return Eval(exp.Alternative, env)
+			return e.Eval(exp.Alternative, env)
 		} else {
 			return NULL
 		}
 	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
-	if !ok {
-		return newError("not a function: %s", fn)
+// evalTryStatement runs the catch block if the try block throws, and the
+// finally block in any case. If the finally block returns, breaks or
+// throws, that overrides what the other blocks did.
+func (e *Evaluator) evalTryStatement(ts *ast.TryStatement, env *object.Environment) object.Object {
+	result := e.Eval(ts.Body, env)
+
+	if err, ok := result.(*object.Error); ok && ts.Catch != nil {
+		catchEnv := object.NewClosedEnvironment(env)
+		if ts.CatchParam != nil {
+			catchEnv.Set(ts.CatchParam.Value, core.Catch(err))
+		}
+
+		result = e.Eval(ts.Catch, catchEnv)
+	}
+
+	if ts.Finally != nil {
+		finally := e.Eval(ts.Finally, env)
+
+		switch finally.(type) {
+		case *object.ReturnValue, *object.Break, *object.Continue, *object.Error:
+			return finally
+		}
 	}
 
+	return result
+}
+
+// evalWhileStatement runs the body in the loop's own environment, but a
+// constant the body declares belongs to one iteration, so the next
+// iteration can declare it again.
+func (e *Evaluator) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	consts := env.Consts()
+
+	for {
+		condition := e.Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		truthy, err := e.rt.Condition(condition)
+		if err != nil {
+			return err
+		}
+
+		if !truthy {
+			return NULL
+		}
+
+		result := e.Eval(ws.Body, env)
+		env.ResetConsts(consts)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		case *object.Break:
+			return NULL
+		}
+	}
+}
+
+// evalForStatement binds each element to the loop variable in turn. As in
+// a while loop, the constants of one iteration are forgotten in the next.
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	elements, err := core.Iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	if env.IsConstInScope(fs.Variable.Value) {
+		return newError("cannot redeclare constant: %s", fs.Variable.Value)
+	}
+
+	consts := env.Consts()
+
+	for _, element := range elements {
+		env.Set(fs.Variable.Value, element)
+
+		result := e.Eval(fs.Body, env)
+		env.ResetConsts(consts)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		case *object.Break:
+			return NULL
+		}
+	}
+
+	return NULL
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object, callSite token.Position) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		return e.applyUserFunction(function, args, callSite)
+	case *object.Builtin:
+		return function.Fn(args...)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// applyUserFunction calls function. An error coming out of its body gets
+// a frame for the call added to its trace.
+func (e *Evaluator) applyUserFunction(function *object.Function, args []object.Object, callSite token.Position) object.Object {
 	if len(function.Parameters) != len(args) {
 		return newError("wrong arguments: expect=%d, got=%d", len(function.Parameters), len(args))
 	}
 
+	if e.depth >= core.MaxCallDepth {
+		return newError("stack overflow")
+	}
+
 	extendedEnv := extendFunctionEnv(function, args)
-	evaluatedFunction := Eval(function.Body, extendedEnv)
+	e.depth++
+	evaluatedFunction := e.Eval(function.Body, extendedEnv)
+	e.depth--
+
+	if err, ok := evaluatedFunction.(*object.Error); ok {
+		err.Trace = append(err.Trace, object.Frame{Function: function.Name, Pos: callSite})
+	}
 
 	if returnValue, ok := evaluatedFunction.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -238,11 +499,11 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	return evaluatedFunction
 }
 
-func evalArgs(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	args := []object.Object{}
 
 	for _, exp := range exps {
-		arg := Eval(exp, env)
+		arg := e.Eval(exp, env)
 		args = append(args, arg)
 		if isError(arg) {
 			return []object.Object{arg}
@@ -264,7 +525,7 @@ func extendFunctionEnv(function *object.Function, args []object.Object) *object.
 }
 
 func newError(format string, args ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, args...)}
+	return &object.Error{Kind: object.RUNTIME_ERROR, Message: fmt.Sprintf(format, args...)}
 }
 
 func isError(obj object.Object) bool {
@@ -1,12 +1,39 @@
-package evaluator
+package evaluator_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/compiler"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/evaluator"
 	"github.com/st0012/monkey/lexer"
 	"github.com/st0012/monkey/object"
 	"github.com/st0012/monkey/parser"
+	"github.com/st0012/monkey/token"
+	"github.com/st0012/monkey/vm"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
+// The tests in this file are the conformance suite of both engines: they
+// run once against the evaluator and once against the compiler and vm.
+var engines = []string{"eval", "vm"}
+
+var engine string
+
+func TestMain(m *testing.M) {
+	for _, engine = range engines {
+		if code := m.Run(); code != 0 {
+			fmt.Fprintf(os.Stderr, "FAIL with engine %q\n", engine)
+			os.Exit(code)
+		}
+	}
+	os.Exit(0)
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -18,6 +45,24 @@ addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestFunctionInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y) { x + y }", "fn(x, y) {\n(x + y)\n}"},
+		{"let f = fn(a) { fn(b) { a * b } }; f(2)", "fn(b) {\n(a * b)\n}"},
+		{"[fn() { 1 }]", "[fn() {\n1\n}]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong inspect for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestFunctionCall(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -48,6 +93,8 @@ func TestLetStatement(t *testing.T) {
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
 		{"let a = 5; let b = 10; let c = if (a > b) { 100; } else { 50; }", 50},
+		{"let π = 3; let größe = π * 2; größe", 6},
+		{`let 名前 = "日本語"; len(名前)`, 3},
 	}
 
 	for _, tt := range tests {
@@ -73,6 +120,22 @@ func TestErrorHandling(t *testing.T) {
 			"-true;",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"~true;",
+			"unknown operator: ~BOOLEAN",
+		},
+		{
+			"2 ** -1",
+			"negative exponent: -1",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			`"a" <= "b"`,
+			"unknown operator: STRING <= STRING",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -101,6 +164,34 @@ func TestErrorHandling(t *testing.T) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			"[1, 2, 3][3]",
+			"index out of range: 3 (length 3)",
+		},
+		{
+			"[1, 2, 3][-4]",
+			"index out of range: -4 (length 3)",
+		},
+		{
+			`[1, 2, 3]["a"]`,
+			"index operator not supported: ARRAY[STRING]",
+		},
+		{
+			"[1, foobar]",
+			"identifier not found: foobar",
+		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			`{[1]: 2}`,
+			"unusable as hash key: ARRAY",
+		},
 		{
 			"let add = fn(x, y) { x + y; }; add(1, 2, 3);",
 			"wrong arguments: expect=2, got=3",
@@ -122,6 +213,117 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedInspect string
+	}{
+		{"foobar", "ERROR: 1:1: identifier not found: foobar"},
+		{"let x = 1;\nlet y = x +\n  true;", "ERROR: 2:11: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() {\n  -true\n};\nf()", "ERROR: 2:3: unknown operator: -BOOLEAN"},
+		{"len(1)", "ERROR: 1:4: argument to `len` not supported, got INTEGER"},
+		{"let a = [1, 2];\na[5]", "ERROR: 2:2: index out of range: 5 (length 2)"},
+		{"let h = {\n  []: 1\n}", "ERROR: 1:9: unusable as hash key: ARRAY"},
+		{"for (x in 1) {\n  x\n}", "ERROR: 1:1: cannot iterate over INTEGER"},
+		{"let f = 1;\nf(2)", "ERROR: 2:2: not a function: INTEGER"},
+		{"const c = 1;\nc = 2", "ERROR: 2:3: cannot assign to constant: c"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedInspect, errObj.Inspect())
+		}
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedTrace []string
+	}{
+		{"1 + true", nil},
+		{"let f = fn() { 1 + true }; f()", []string{"f@1:29"}},
+		{"let f = fn() { len(1) }; f()", []string{"f@1:27"}},
+		{"let f = fn(x) { x }; f()", nil},
+		{
+			"let inner = fn() { 1 / 0 };\nlet outer = fn() { inner() };\nouter()",
+			[]string{"inner@2:25", "outer@3:6"},
+		},
+		{"fn() { 1 / 0 }()", []string{"@1:15"}},
+		{
+			"let count = fn(n) { if (n == 0) { x } else { count(n - 1) } }; count(2)",
+			[]string{"count@1:51", "count@1:51", "count@1:69"},
+		},
+		{"let f = fn() { 1 / 0 }; try { f() } catch (e) { g() }", nil},
+		{
+			"let f = fn() { 1 / 0 };\nlet g = fn() { try { f() } catch (e) { throw e } };\ng()",
+			[]string{"f@2:23", "g@3:2"},
+		},
+		{
+			"let f = fn() { 1 / 0 };\nlet g = fn() { try { f() } finally { 1 } };\ng()",
+			[]string{"f@2:23", "g@3:2"},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		trace := []string{}
+		for _, frame := range errObj.Trace {
+			trace = append(trace, frame.Function+"@"+frame.Pos.String())
+		}
+
+		if len(trace) != len(tt.expectedTrace) {
+			t.Errorf("wrong trace for %s. expected=%v, got=%v", tt.input, tt.expectedTrace, trace)
+			continue
+		}
+
+		for i, frame := range tt.expectedTrace {
+			if trace[i] != frame {
+				t.Errorf("wrong trace for %s. expected=%v, got=%v", tt.input, tt.expectedTrace, trace)
+				break
+			}
+		}
+	}
+}
+
+func TestStackTraceFormat(t *testing.T) {
+	err := &object.Error{
+		Message: "boom",
+		Pos:     token.Position{Filename: "a.mk", Line: 3, Column: 7},
+		Trace: []object.Frame{
+			{Function: "inner", Pos: token.Position{Filename: "a.mk", Line: 5, Column: 2}},
+			{Function: "", Pos: token.Position{Filename: "a.mk", Line: 9, Column: 4}},
+		},
+	}
+
+	expected := "inner()\n\ta.mk:3:7\n" +
+		"<anonymous>()\n\ta.mk:5:2\n" +
+		"<main>\n\ta.mk:9:4\n"
+
+	if err.StackTrace() != expected {
+		t.Errorf("wrong stack trace. expected=%q, got=%q", expected, err.StackTrace())
+	}
+
+	if (&object.Error{Message: "boom"}).StackTrace() != "" {
+		t.Errorf("an error without frames has a stack trace")
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -181,6 +383,7 @@ func TestEvalIfExpression(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (if (false) { 1 }) { 10 }", nil},
 	}
 
 	for _, tt := range tests {
@@ -198,6 +401,438 @@ func TestEvalIfExpression(t *testing.T) {
 	}
 }
 
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (fn() {}) { 10 } else { 20 }", 10},
+		{`if ("") { 10 } else { 20 }`, 10},
+		{"if ([]) { 10 } else { 20 }", 10},
+		{"if (0) { 10 } else { 20 }", 10},
+		{"if (if (false) { 1 }) { 10 } else { 20 }", 20},
+		{"!fn() {}", false},
+		{"!(if (false) { 1 })", true},
+		{"let i = 0; while (if (i < 3) { i }) { i += 1 }; i", 3},
+		{"(fn() {}) && 1", 1},
+		{"let n = if (false) { 1 }; n || 2", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestStrictConditions(t *testing.T) {
+	options := core.DefaultOptions()
+	options.StrictConditions = true
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"!true", false},
+		{"true && false || true", true},
+		{"let i = 0; while (i < 3) { i += 1 }; i", 3},
+		{"if (1) { 10 }", "condition must be BOOLEAN, got INTEGER"},
+		{"if (fn() {}) { 10 }", "condition must be BOOLEAN, got FUNCTION"},
+		{"!5", "condition must be BOOLEAN, got INTEGER"},
+		{"while (if (false) { 1 }) { }", "condition must be BOOLEAN, got NULL"},
+		{`"a" && true`, "condition must be BOOLEAN, got STRING"},
+		{"1 || true", "condition must be BOOLEAN, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, options)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { 1 / 0 } catch (e) { e["message"] }`, `"division by zero"`},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, `"RuntimeError"`},
+		{"try {\n  1 / 0\n} catch (e) { e[\"position\"] }", `"2:5"`},
+		{`try { throw "oops" } catch (e) { e["kind"] + ": " + e["message"] }`, `"Error: oops"`},
+		{`try { throw error("bad input", "ValueError") } catch (e) { e }`, "ValueError: bad input"},
+		{`try { throw error("x") } catch (e) { type(e) }`, `"ERROR_VALUE"`},
+		{`try { throw 1 } catch (e) { e["message"] }`, `"cannot throw INTEGER"`},
+		{`try { foo } catch { 2 }`, 2},
+		{`try { try { 1 / 0 } catch (e) { throw e } } catch (e) { e["position"] }`, `"1:15"`},
+		{`let e = 1; try { throw "x" } catch (e) { }; e`, 1},
+		{`const e = 1; try { throw "x" } catch (e) { e["message"] }`, `"x"`},
+		{`let f = fn() { throw "inner" }; try { f() } catch (e) { e["message"] }`, `"inner"`},
+		{`let log = []; try { log = push(log, 1) } finally { log = push(log, 2) }; log`, "[1, 2]"},
+		{`let log = []; try { try { 1 / 0 } finally { log = push(log, "finally") } } catch (e) { log = push(log, e["message"]) }; log`, `["finally", "division by zero"]`},
+		{`let x = 0; let f = fn() { try { return 1 } finally { x = 2 } }; f() + x`, 3},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let n = 0; while (true) { try { break } finally { n += 1 } }; n`, 1},
+		{`let n = 0; for (i in [1, 2, 3]) { try { if (i == 2) { continue } } finally { n += 1 } }; n`, 3},
+		{`try { 1 / 0 } catch (e) { throw error("wrapped: " + e["message"]) }`, "wrapped: division by zero"},
+		{`try { 1 } finally { throw "from finally" }`, "from finally"},
+		{`let f = fn(n) { if (n == 0) { 1 / 0 } else { f(n - 1) } }; try { f(10) } catch (e) { e["message"] }`, `"division by zero"`},
+		{`1 + (fn() { try { 1 / 0 } catch (e) { 2 } })()`, 3},
+		{`try { for (x in [1, 2]) { 1 / 0 } } catch (e) { 5 }`, 5},
+		{`try { throw "x" } catch (e) { let y = 1 }; y`, "identifier not found: y"},
+		{`let n = 0; for (i in [1, 2, 3]) { try { 1 / 0 } catch (e) { if (i == 2) { break } } finally { n += i } }; n`, 3},
+		{`let f = fn() { for (i in [1]) { try { return i } finally { } } }; f()`, 1},
+		{`let log = []; try { try { throw "a" } finally { log = push(log, 1) } } catch (e) { log = push(log, e["message"]) } finally { log = push(log, 2) }; log`, `[1, "a", 2]`},
+		{`let n = 0; while (n < 3) { try { throw "x" } finally { n += 1; continue } }; n`, 3},
+		{`let n = 0; while (n < 5000) { try { n } finally { n += 1; continue } }; n`, 5000},
+		{`throw "uncaught"`, "uncaught"},
+		{`error()`, "wrong arguments: expect=1, got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"util.mk":         `let secret = 41; export let helper = fn(x) { x + secret }; export const name = "util"`,
+		"counter.mk":      `export let count = 0; export let bump = fn() { count += 1 }`,
+		"nested/outer.mk": `import "inner.mk" as inner; export let value = inner.value * 2`,
+		"nested/inner.mk": `export let value = 21`,
+		"cycle_a.mk":      `import "cycle_b.mk" as b`,
+		"cycle_b.mk":      `import "cycle_a.mk" as a`,
+		"broken.mk":       `let x = ;`,
+		"failing.mk":      `export let x = 1 / 0`,
+		"greeting.mk":     `puts("hello")`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options := core.DefaultOptions()
+	options.ModulePaths = []string{dir}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "util.mk" as util; util.helper(1)`, 42},
+		{`import "util.mk" as util; util.name`, `"util"`},
+		{`import "util.mk" as util; type(util)`, `"MODULE"`},
+		{`import "util.mk" as util; util.secret`, "module " + filepath.Join(dir, "util.mk") + " has no export named secret"},
+		{`import "counter.mk" as a; import "counter.mk" as b; a.bump(); a.bump(); b.count`, 2},
+		// Every run loads its modules afresh.
+		{`import "counter.mk" as c; c.count`, 0},
+		{`let f = fn() { import "counter.mk" as c; c.bump() }; f(); f()`, 2},
+		{`import "nested/outer.mk" as outer; outer.value`, 42},
+		{`import "` + filepath.Join(dir, "util.mk") + `" as util; util.helper(0)`, 41},
+		{`import "missing.mk" as m`, "module not found: missing.mk"},
+		{
+			`import "cycle_a.mk" as a`,
+			"import cycle: " + filepath.Join(dir, "cycle_a.mk") + " -> " + filepath.Join(dir, "cycle_b.mk") + " -> " + filepath.Join(dir, "cycle_a.mk"),
+		},
+		{
+			`import "broken.mk" as b`,
+			"could not parse module " + filepath.Join(dir, "broken.mk") + ":\n" + filepath.Join(dir, "broken.mk") + ":1:9: no prefix function for ;.",
+		},
+		{`import "failing.mk" as f`, "division by zero"},
+		{`let x = 1; x.y`, "member access not supported: INTEGER.y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, options)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+
+	// A module prints where the program that imports it does.
+	var out bytes.Buffer
+	options.Stdout = &out
+	testEvalWithOptions(`import "greeting.mk" as g; puts("bye")`, options)
+	if out.String() != "hello\nbye\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = 2", 2},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let x = 10; x %= 4", 2},
+		{`let s = "a"; s += "b"; s`, `"ab"`},
+		{"let x = 1; let f = fn() { x = x + 1 }; f(); f(); x", 3},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn() { let x = 1; let g = fn() { x = 5 }; g(); x }; f()", 5},
+		{"let f = fn() { x = 2 }; let x = 1; f(); x", 2},
+		{"let i = 0; let sum = 0; while (i < 4) { i += 1; sum += i }; sum", 10},
+		{"let a = [1, 2, 3]; a[0] = 5; a[-1] += 10; a[0] + a[2]", 18},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] + h["b"]`, 7},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"x = 1", "identifier not found: x"},
+		{"let f = fn() { y = 1 }; f()", "identifier not found: y"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 (length 1)"},
+		{`let a = [1]; a["a"] = 2`, "index assignment not supported: ARRAY[STRING]"},
+		{"let h = {}; h[[1]] = 2", "unusable as hash key: ARRAY"},
+		{`let h = {}; h["a"] += 1`, "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestConstBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const x = 1; x", 1},
+		{"const x = 1; const y = x + 1; y", 2},
+		{"let f = fn() { const y = 1; y }; f() + f()", 2},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; f()", 3},
+		{"const x = 1; let f = fn(x) { x += 1 }; f(5)", 6},
+		{"const a = [1]; a[0] = 2; a[0]", 2},
+		{"let x = 1; const x = 2; x", 2},
+		{"let i = 0; while (i < 3) { const c = i; i += 1 }; i", 3},
+		{"let s = 0; for (x in [1, 2, 3]) { const d = x * 2; s += d }; s", 12},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2]) { const c = x * y; n += c } }; n", 9},
+		{"const x = 1; x = 2", "cannot assign to constant: x"},
+		{"let f = fn() { x = 2 }; const x = 1; f()", "cannot assign to constant: x"},
+		{"let f = fn() { x *= 2 }; const x = 1; f()", "cannot assign to constant: x"},
+		{"const x = 1; let x = 2", "cannot redeclare constant: x"},
+		{"const x = 1; const x = 2", "cannot redeclare constant: x"},
+		{"const x = 1; for (x in [1]) { }", "cannot redeclare constant: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	countDown := "let g = fn(n) { if (n == 0) { 0 } else { 1 + g(n - 1) } }; "
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{countDown + "g(2000)", 2000},
+		{countDown + "g(9999)", 9999},
+		{countDown + "g(10000)", "stack overflow"},
+		{"let f = fn(n) { f(n + 1) }; f(0)", "stack overflow"},
+		{`let f = fn(n) { f(n + 1) }; try { f(0) } catch (e) { e["message"] }`, `"stack overflow"`},
+		{"let f = fn(n) { f(n + 1) }; let r = 0; try { f(0) } catch (e) { r = 1 }; " + countDown + "r + g(9999)", 10000},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1 }; i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 3) { break } }; i", 4},
+		{"let i = 0; let sum = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue }; let sum = sum + i }; sum", 13},
+		{"while (false) { 1 }", nil},
+		{"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x }; sum", 6},
+		{`let s = ""; for (c in "abc") { let s = c + s }; s`, `"cba"`},
+		{`let s = ""; for (k in {"a": 1, "b": 2}) { let s = s + k }; s`, `"ab"`},
+		{"let last = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break }; let last = x }; last", 2},
+		{"let n = 0; for (x in [1, 2, 3]) { for (y in [1, 2, 3]) { if (y > x) { break }; let n = n + 1 } }; n", 6},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x } } }; f()", 2},
+		{"let f = fn(n) { let i = 0; while (i < n) { let i = i + 1 }; i }; f(3)", 3},
+		{"let f = fn() { for (x in []) { x } }; f()", nil},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue } else { let n = n + x } }; n", 8},
+		{"let i = 0; while (true) { let i = i + 1; if (i < 3) { 1 } else { if (i == 5) { break } } }; i", 5},
+		{"let n = if (true) { let i = 0; while (true) { let i = i + 1; if (i == 3) { break } }; i }; n", 3},
+		{"let xs = [1, if (true) { for (x in [1, 2]) { if (x == 2) { continue }; x } ; 2 }, 3]; len(xs) + xs[1]", 5},
+		{"for (x in 1) { x }", "cannot iterate over INTEGER"},
+		{"while (true) { foobar }", "identifier not found: foobar"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false && false", true},
+		{"false && true || true", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 && 2", 2},
+		{"false && 2", false},
+		{"1 || 2", 1},
+		{`false || "a"`, `"a"`},
+		{"let n = if (false) { 1 }; n || 5", 5},
+		{"let n = if (false) { 1 }; n && 5", nil},
+		{"let x = 0; false && (x = 1); x", 0},
+		{"let x = 0; true || (x = 1); x", 0},
+		{"let x = 0; true && (x = 1); x", 1},
+		{"false && foobar", false},
+		{"true && foobar", "identifier not found: foobar"},
+		{"foobar || true", "identifier not found: foobar"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
 func TestEvalInfixIntegerExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -218,6 +853,22 @@ func TestEvalInfixIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"17 % 5", 2},
+		{"-17 % 5", -2},
+		{"2 + 10 % 4 * 3", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 0", 1},
+		{"2 ** 3 ** 2", 512},
+		{"2 * 3 ** 2", 18},
+		{"-2 ** 2", 4},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 << 2 + 1", 8},
+		{"1 | 2 ^ 3 & 4", 3},
 	}
 
 	for _, tt := range tests {
@@ -226,6 +877,275 @@ func TestEvalInfixIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerArithmeticErrors(t *testing.T) {
+	options := core.DefaultOptions()
+	options.PromoteOnOverflow = false
+
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"let x = 1; x /= 0", "division by zero"},
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"2 ** 63", "integer overflow: 2 ** 63"},
+		{"(-9223372036854775807 - 1) / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"-(-9223372036854775807 - 1)", "integer overflow: -(-9223372036854775808)"},
+		{"int(1e300)", "integer overflow: 1e+300"},
+		{"1 << 64", "integer overflow: 1 << 64"},
+		{"3 << 62", "integer overflow: 3 << 62"},
+		{"99999999999999999999", "integer overflow: 99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, options)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestPromoteOnOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"2 ** 64", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"-(2 ** 63)", -9223372036854775808},
+		{"2 ** 64 / 2 ** 32", 4294967296},
+		{"2 ** 64 - 2 ** 64", 0},
+		{"2 ** 64 % 10", 6},
+		{"2 ** 64 > 9223372036854775807", true},
+		{"2 ** 64 == 2 ** 64", true},
+		{"type(2 ** 64)", `"BIG_INT"`},
+		{"{2 ** 64: 1}[2 ** 64]", 1},
+		{"2 ** 64 / 0", "division by zero"},
+		{"2 ** 64 & 1", 0},
+		{"(2 ** 64 + 5) & 7", 5},
+		{"2 ** 64 | 1", "18446744073709551617"},
+		{"2 ** 64 ^ 2 ** 64", 0},
+		{"~(2 ** 64)", "-18446744073709551617"},
+		{"1 << 64", "18446744073709551616"},
+		{"3 << 62", "13835058055282163712"},
+		{"-1 << 63", -9223372036854775808},
+		{"2 ** 64 >> 60", 16},
+		{"-(2 ** 64) >> 100", -1},
+		{"2 ** 64 << -1", "negative shift count: -1"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"0xFFFFFFFFFFFFFFFFF", "295147905179352825855"},
+		{"-9223372036854775808", -9223372036854775808},
+		{"2 ** 100000000000", "number too large: 2 ** 100000000000"},
+		{"(2 ** 64) ** 1000000", "number too large: 18446744073709551616 ** 1000000"},
+		{"1 << 10000000000", "number too large: 1 << 10000000000"},
+		{"1 ** 100000000000", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestFloatExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"3.14", 3.14},
+		{".5", 0.5},
+		{"1e-9", 1e-9},
+		{"1_000.5", 1000.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"7 / 2", 3},
+		{"7.5 % 2", 1.5},
+		{"2 ** 0.5 * 2 ** 0.5", 2.0000000000000004},
+		{"2.0 ** -1", 0.5},
+		{"9223372036854775807 * 2.0", 18446744073709551616.0},
+		{"let x = 1; x += 0.5; x", 1.5},
+		{"1.5 < 2", true},
+		{"2 <= 1.5", false},
+		{"1.0 == 1", true},
+		{"0.1 + 0.2 != 0.3", true},
+		{"1.0 / 0", "division by zero"},
+		{"1.5 % 0.0", "division by zero"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{`1.5 + "a"`, "type mismatch: FLOAT + STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.0", "3.0"},
+		{"3.25", "3.25"},
+		{"1e21", "1e+21"},
+		{"-0.5", "-0.5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect() for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDecimalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"12.50d", "12.50d"},
+		{"-0.05d", "-0.05d"},
+		{"1.5e3d", "1500d"},
+		{"1_000.00d", "1000.00d"},
+		{"0.1d + 0.2d", "0.3d"},
+		{"0.1d + 0.2d == 0.3d", true},
+		{"1.10d + 2.205d", "3.305d"},
+		{"10d - 0.01d", "9.99d"},
+		{"12.50d * 3", "37.50d"},
+		{"1.5d * 1.5d", "2.25d"},
+		{"10.00d / 4", "2.50d"},
+		{"1d / 3", "0.3333333333333333d"},
+		{"2d / 3", "0.6666666666666667d"},
+		{"10.5d % 3", "1.5d"},
+		{"1.1d ** 2", "1.21d"},
+		{"2d ** -2", "0.25d"},
+		{"9223372036854775807 + 0.5d", "9223372036854775807.5d"},
+		{"let total = 0d; for (x in [0.1d, 0.1d, 0.1d]) { total += x }; total", "0.3d"},
+		{"1.0d == 1", true},
+		{"1.5d > 1.25d", true},
+		{"-1.5d <= -1.5d", true},
+		{"{1.5d: true}[1.50d]", true},
+		{`{1: "a"}[1d]`, `"a"`},
+		{`{1.00d: "a"}[1]`, `"a"`},
+		{"{2 ** 64: true}[18446744073709551616.0d]", true},
+		{"1.5d ** 1000000000", "number too large: 1.5d ** 1000000000"},
+		{"0.1d ** -1000000000", "number too large: 0.1d ** -1000000000"},
+		{"round(1.5d, 100000000)", "number of digits must be at most 1000000, got 100000000"},
+		{`decimal("1e999999999")`, `cannot convert "1e999999999" to DECIMAL`},
+		{"1d / 0", "division by zero"},
+		{"1d % 0.0d", "division by zero"},
+		{"1.5d ** 0.5d", "exponent of a DECIMAL must be INTEGER, got DECIMAL"},
+		{"1.5d + 1.5", "type mismatch: DECIMAL + FLOAT"},
+		{"1.5d & 1", "unknown operator: DECIMAL & INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	tests := []struct {
+		mode     string
+		input    string
+		expected string
+	}{
+		{"half_even", "round(2.345d, 2)", "2.34d"},
+		{"half_even", "round(2.355d, 2)", "2.36d"},
+		{"half_even", "round(2.5d)", "2"},
+		{"half_up", "round(2.345d, 2)", "2.35d"},
+		{"half_up", "round(-2.5d)", "-3"},
+		{"half_down", "round(2.345d, 2)", "2.34d"},
+		{"half_down", "round(2.3451d, 2)", "2.35d"},
+		{"up", "round(2.301d, 1)", "2.4d"},
+		{"down", "round(2.399d, 1)", "2.3d"},
+		{"ceiling", "round(-2.39d, 1)", "-2.3d"},
+		{"floor", "round(-2.31d, 1)", "-2.4d"},
+		{"half_up", "2d / 3", "0.6666666666666667d"},
+		{"down", "2d / 3", "0.6666666666666666d"},
+		{"half_even", "round(2.5d, 3)", "2.500d"},
+	}
+
+	for _, tt := range tests {
+		mode, ok := core.ParseRoundingMode(tt.mode)
+		if !ok {
+			t.Fatalf("unknown rounding mode %q", tt.mode)
+		}
+
+		options := core.DefaultOptions()
+		options.DecimalRounding = mode
+
+		evaluated := testEvalWithOptions(tt.input, options)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s with %s. expected=%s, got=%+v", tt.input, tt.mode, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestEvalInfixBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -250,6 +1170,13 @@ func TestEvalInfixBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"3 >= 2", true},
+		{"1 | 2 == 3", true},
 	}
 
 	for _, tt := range tests {
@@ -269,6 +1196,9 @@ func TestEvalBangPrefixExpression(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		{"!(1 > 2)", true},
+		{"!(1 < 2)", false},
+		{"!if (false) { 1 }", true},
 	}
 
 	for _, tt := range tests {
@@ -301,6 +1231,107 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}{
 		{"5", 5},
 		{"10", 10},
+		{"0xFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0xff_ff + 1", 65536},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+	if str.Inspect() != `"Hello World!"` {
+		t.Errorf("String.Inspect() wrong. got=%s", str.Inspect())
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!\n"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!\n" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+	if str.Inspect() != `"Hello World!\n"` {
+		t.Errorf("String.Inspect() wrong. got=%s", str.Inspect())
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+
+	if result.Inspect() != "[1, 4, 6]" {
+		t.Errorf("Array.Inspect() wrong. got=%s", result.Inspect())
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
 	}
 
 	for _, tt := range tests {
@@ -309,6 +1340,179 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	expectedInspect := `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}`
+	if result.Inspect() != expectedInspect {
+		t.Errorf("Hash.Inspect() wrong. expected=%s, got=%s", expectedInspect, result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("\u{e9}t\u{e9}")`, 3},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong arguments: expect=1, got=2"},
+		{`puts("hello", 1)`, nil},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int64{2, 3}},
+		{`rest([1])`, []int64{}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int64{1}},
+		{`let a = [1]; push(a, 2); a`, []int64{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong arguments: expect=2, got=1"},
+		{`type(1)`, `"INTEGER"`},
+		{`type("a")`, `"STRING"`},
+		{`type(len)`, `"BUILTIN"`},
+		{`type()`, "wrong arguments: expect=1, got=0"},
+		{`let len = fn(x) { 42 }; len("a")`, 42},
+		{`1(2)`, "not a function: INTEGER"},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int("42")`, 42},
+		{`int(7)`, 7},
+		{`int("4.2")`, `cannot convert "4.2" to INTEGER`},
+		{`int(1e19)`, "10000000000000000000"},
+		{`int(true)`, "argument to `int` not supported, got BOOLEAN"},
+		{`type(float(2))`, `"FLOAT"`},
+		{`float("2.5") * 2 == 5`, `true`},
+		{`decimal("12.50")`, "12.50d"},
+		{`decimal(0.1)`, "0.1d"},
+		{`decimal(3)`, "3d"},
+		{`decimal("abc")`, `cannot convert "abc" to DECIMAL`},
+		{`float(1.25d)`, "1.25"},
+		{`int(-2.99d)`, -2},
+		{`floor(-2.5d)`, -3},
+		{`ceil(2.01d)`, 3},
+		{`round(2.5d, -1)`, "number of digits must not be negative, got -1"},
+		{`float("x")`, `cannot convert "x" to FLOAT`},
+		{`round(2.5)`, 3},
+		{`round(-2.5)`, -3},
+		{`round(2.4)`, 2},
+		{`round(5)`, 5},
+		{`round(3.14159, 2) == 3.14`, `true`},
+		{`round(1, 2, 3)`, "wrong arguments: expect=2, got=3"},
+		{`floor(2.7)`, 2},
+		{`floor(-2.5)`, -3},
+		{`ceil(2.1)`, 3},
+		{`ceil("a")`, "argument to `ceil` not supported, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%+v", tt.input, expected, evaluated)
+			}
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], expectedElem)
+			}
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -325,11 +1529,45 @@ func TestEvalBooleanExpression(t *testing.T) {
 }
 
 func testEval(input string) object.Object {
+	return testEvalWithOptions(input, core.DefaultOptions())
+}
+
+func testEvalWithOptions(input string, options core.Options) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	rt := core.New(options)
 
-	return Eval(program, object.NewEnvironment())
+	if engine == "vm" {
+		return testRunVM(program, rt)
+	}
+
+	return evaluator.New(rt).Eval(program, object.NewEnvironment())
+}
+
+// testRunVM compiles and runs the program, returning errors as
+// *object.Error so tests can check them the same way for both engines.
+func testRunVM(program *ast.Program, rt *core.Runtime) object.Object {
+	comp := compiler.New()
+	comp.SetModulePaths(rt.ModulePaths)
+	if err := comp.Compile(program); err != nil {
+		var compileErr *compiler.Error
+		if errors.As(err, &compileErr) {
+			return &object.Error{Kind: object.RUNTIME_ERROR, Message: compileErr.Message, Pos: compileErr.Pos}
+		}
+		return &object.Error{Message: err.Error()}
+	}
+
+	machine := vm.New(comp.Bytecode(), rt)
+	if err := machine.Run(); err != nil {
+		var runtimeErr *object.Error
+		if errors.As(err, &runtimeErr) {
+			return runtimeErr
+		}
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.LastPoppedStackElem()
 }
 
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
@@ -339,7 +1577,7 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 		return false
 	}
 	if result.Value != expected {
-		t.Errorf("object has wrong value. expect=%d, got=%d", expected, result.Value)
+		t.Errorf("object has wrong value. expect=%t, got=%t", expected, result.Value)
 		return false
 	}
 
@@ -360,8 +1598,22 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. expect=%g, got=%g", expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
-	if obj != NULL {
+	if obj != evaluator.NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
 		return false
 	}
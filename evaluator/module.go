@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/object"
+)
+
+func (e *Evaluator) evalImportStatement(is *ast.ImportStatement, env *object.Environment) object.Object {
+	module := e.loader.Import(is.Path.Value, is.Pos().Filename, e.loadModule)
+	if isError(module) {
+		return module
+	}
+
+	return env.Set(is.Name.Value, module)
+}
+
+// loadModule evaluates the program of the file at path in an environment of
+// its own. It runs with the evaluator's runtime, so the module writes where
+// the program that imports it does.
+func (e *Evaluator) loadModule(path string, program *ast.Program) object.Object {
+	env := object.NewEnvironment()
+	if result := e.Eval(program, env); isError(result) {
+		return result
+	}
+
+	module := &object.Module{Path: path, Env: env, Exports: map[string]bool{}}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			module.Exports[export.Statement.Name.Value] = true
+		}
+	}
+
+	return module
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError("member access not supported: %s.%s", obj.Type(), name)
+	}
+
+	if val, ok := module.Member(name); ok {
+		return val
+	}
+
+	return newError("module %s has no export named %s", module.Path, name)
+}
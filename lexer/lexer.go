@@ -1,21 +1,58 @@
 package lexer
 
 import (
+	"fmt"
 	"github.com/st0012/monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Lexer reads the source one rune at a time. position and readPosition are
+// byte offsets into input, so the source text of a token can be sliced
+// out of it.
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+
+	// filename, line and column locate ch in the source.
+	filename string
+	line     int
+	column   int
+
+	emitComments bool
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename(input, "")
+}
+
+// NewWithFilename returns a lexer whose token positions carry the given filename.
+func NewWithFilename(input, filename string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
+	l.skipShebang()
 	return l
+}
 
+// SetEmitComments makes the lexer return comments as COMMENT tokens instead
+// of skipping them, for tools that need to preserve them.
+func (l *Lexer) SetEmitComments(emit bool) {
+	l.emitComments = emit
+}
+
+// skipShebang skips a leading `#!` line so scripts can be made executable.
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
 }
 
 func (l *Lexer) NextToken() token.Token {
@@ -23,6 +60,19 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	pos := l.currentPosition()
+
+	if l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		comment, ok := l.readComment()
+		if !ok {
+			return token.Token{Type: token.UNTERMINATED, Literal: "unterminated block comment", Pos: pos}
+		}
+		if l.emitComments {
+			return token.Token{Type: token.COMMENT, Literal: comment, Pos: pos}
+		}
+		return l.NextToken()
+	}
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -33,7 +83,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newAssignToken(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			current_byte := l.ch
@@ -42,28 +92,87 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.newAssignToken(token.SLASH, token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.newTwoCharToken(token.POWER)
+		} else {
+			tok = l.newAssignToken(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
+	case '%':
+		tok = l.newAssignToken(token.PERCENT, token.PERCENT_ASSIGN)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.newTwoCharToken(token.LSHIFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.newTwoCharToken(token.RSHIFT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		if isDigit(l.peekChar()) {
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = pos
+			return tok
+		}
+		tok = newToken(token.DOT, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newAssignToken(token.PLUS, token.PLUS_ASSIGN)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case '"':
+		str, ok := l.readString()
+		switch {
+		case ok:
+			tok = token.Token{Type: token.STRING, Literal: str}
+		case l.ch == 0:
+			tok = token.Token{Type: token.UNTERMINATED, Literal: str}
+		default:
+			tok = token.Token{Type: token.ILLEGAL, Literal: str}
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -71,32 +180,277 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = pos
 			return tok
+		} else if l.invalidUTF8() {
+			tok = token.Token{Type: token.ILLEGAL, Literal: "invalid UTF-8 encoding"}
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
+// newAssignToken returns the compound assignment token if the current
+// character is followed by `=`, and the plain token otherwise.
+func (l *Lexer) newAssignToken(plain, assign token.TokenType) token.Token {
+	if l.peekChar() != '=' {
+		return newToken(plain, l.ch)
+	}
+
+	return l.newTwoCharToken(assign)
+}
+
+// newTwoCharToken returns a token made of the current and the next
+// character.
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	current_byte := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(current_byte) + string(l.ch)}
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
-func (l *Lexer) readNumber() string {
+// readComment reads a `//` line comment or a `/* */` block comment, which
+// may be nested. It returns false if a block comment is never closed.
+func (l *Lexer) readComment() (string, bool) {
 	position := l.position
-	for isDigit(l.ch) {
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return l.input[position:l.position], true
+	}
+
+	l.readChar() // '*'
+	l.readChar()
+
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.ch == 0:
+			return l.input[position:l.position], false
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	return l.input[position:l.position], true
+}
+
+// readNumber reads an integer or a float literal like `3.14`, `.5` or
+// `1e-9`. A number followed by a `d` suffix, like `12.50d`, is a decimal.
+// Digits may be separated by underscores, as in `1_000_000`. A malformed
+// number is returned as an ILLEGAL token describing the problem.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	if l.ch == '0' && strings.ContainsRune("xXoObB", l.peekChar()) {
+		return l.readPrefixedInteger()
+	}
+
+	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekSecondChar())) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	if l.ch == 'd' && !isLetter(l.peekChar()) && !isDigit(l.peekChar()) {
+		tokenType = token.DECIMAL
+		l.readChar()
+	}
+
+	literal := l.input[position:l.position]
+	if !validSeparators(literal, isDigit) {
+		return malformedNumber(literal, "'_' must separate successive digits")
+	}
+
+	return literal, tokenType
+}
+
+// readPrefixedInteger reads a hexadecimal, octal or binary integer like
+// `0xFF`, `0o17` or `0b1010`.
+func (l *Lexer) readPrefixedInteger() (string, token.TokenType) {
+	position := l.position
+
+	l.readChar()
+	l.readChar()
+	for isLetter(l.ch) || isDigit(l.ch) {
+		l.readChar()
+	}
+
+	literal := l.input[position:l.position]
+	base, isBaseDigit := integerBase(rune(literal[1]))
+
+	// Like in Go, a separator is allowed right after the prefix.
+	digits := strings.TrimPrefix(literal[2:], "_")
+	if strings.Trim(digits, "_") == "" {
+		return malformedNumber(literal, "missing %s digits", base)
+	}
+
+	for _, ch := range digits {
+		if ch != '_' && !isBaseDigit(ch) {
+			return malformedNumber(literal, "invalid digit %q in %s literal", ch, base)
+		}
+	}
+
+	if !validSeparators(digits, isBaseDigit) {
+		return malformedNumber(literal, "'_' must separate successive digits")
+	}
+
+	return literal, token.INT
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// integerBase returns the name and the digits of the base an integer
+// prefix like `x` stands for.
+func integerBase(prefix rune) (string, func(rune) bool) {
+	switch prefix {
+	case 'x', 'X':
+		return "hexadecimal", isHexDigit
+	case 'o', 'O':
+		return "octal", func(ch rune) bool { return '0' <= ch && ch <= '7' }
+	default:
+		return "binary", func(ch rune) bool { return ch == '0' || ch == '1' }
+	}
+}
+
+// validSeparators reports whether every underscore in a number literal sits
+// between two digits.
+func validSeparators(literal string, isDigit func(rune) bool) bool {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+
+		if i == 0 || i == len(literal)-1 || !isDigit(rune(literal[i-1])) || !isDigit(rune(literal[i+1])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// malformedNumber returns an ILLEGAL token literal that describes what's
+// wrong with a number literal.
+func malformedNumber(literal, format string, args ...interface{}) (string, token.TokenType) {
+	return fmt.Sprintf("malformed number %q: ", literal) + fmt.Sprintf(format, args...), token.ILLEGAL
+}
+
+// readString reads a double-quoted string and returns its unescaped value.
+// If the string is unterminated or contains an invalid escape sequence,
+// it returns the raw source text and false. If it isn't valid UTF-8, it
+// returns a message saying so and false.
+func (l *Lexer) readString() (string, bool) {
+	start := l.position
+	valid := true
+	validUTF8 := true
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			if !validUTF8 {
+				return "invalid UTF-8 encoding in string", false
+			}
+			if !valid {
+				return l.input[start:l.readPosition], false
+			}
+			return out.String(), true
+		case 0:
+			return l.input[start:l.position], false
+		case '\\':
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				r, ok := l.readUnicodeEscape()
+				valid = valid && ok
+				out.WriteRune(r)
+			case 0:
+				return l.input[start:l.position], false
+			default:
+				valid = false
+			}
+		default:
+			validUTF8 = validUTF8 && !l.invalidUTF8()
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readUnicodeEscape reads the `{...}` part of a `\u{...}` escape sequence.
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return 0, false
+	}
+	l.readChar()
+
+	position := l.position + 1
+	for l.peekChar() != '}' {
+		if l.peekChar() == 0 || l.peekChar() == '"' {
+			return 0, false
+		}
+		l.readChar()
+	}
+
+	code, err := strconv.ParseUint(l.input[position:l.readPosition], 16, 32)
+	l.readChar() // '}'
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, false
+	}
+
+	return rune(code), true
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -108,33 +462,69 @@ func (l *Lexer) readIdentifier() string {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		// ascii code's null
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 	// Peek shouldn't increment positions.
 }
 
-func isDigit(ch byte) bool {
+// peekSecondChar returns the character after the one peekChar returns.
+func (l *Lexer) peekSecondChar() rune {
+	if l.readPosition >= len(l.input) {
+		return 0
+	}
+
+	_, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	if l.readPosition+width >= len(l.input) {
+		return 0
+	}
+
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition+width:])
+	return ch
+}
+
+// invalidUTF8 reports whether ch was decoded from a byte that isn't valid
+// UTF-8, as opposed to an encoded U+FFFD.
+func (l *Lexer) invalidUTF8() bool {
+	return l.ch == utf8.RuneError && l.readPosition-l.position == 1
+}
+
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func isLetter(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+	}
+	return unicode.IsLetter(ch)
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
@@ -15,7 +15,7 @@ func TestNextToken(t *testing.T) {
 	};
 
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 >5;
 
 	if (5 < 10) {
@@ -27,6 +27,13 @@ func TestNextToken(t *testing.T) {
 	10 == 10;
 
 	10 != 9;
+	"foobar"
+	"foo bar"
+	[1, 2];
+	{"foo": "bar"}
+	a += b -= c *= d /= e %= f
+	a && b || c
+	<= >= % ** & | ^ ~ << >>
 	`
 
 	tests := []struct {
@@ -106,6 +113,45 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.IDENT, "b"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.IDENT, "c"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.IDENT, "d"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.IDENT, "e"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.IDENT, "f"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.LSHIFT, "<<"},
+		{token.RSHIFT, ">>"},
 		{token.EOF, ""},
 	}
 
@@ -124,3 +170,278 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNumbers(t *testing.T) {
+	input := "3.14 .5 1e-9 2E3 1.5e+2 10 1.foo 1e x 12.50d 3d 1e3d 2do"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, ".5"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2E3"},
+		{token.FLOAT, "1.5e+2"},
+		{token.INT, "10"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENT, "foo"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x"},
+		{token.DECIMAL, "12.50d"},
+		{token.DECIMAL, "3d"},
+		{token.DECIMAL, "1e3d"},
+		{token.INT, "2"},
+		{token.IDENT, "do"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. exprected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. exprected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIntegerLiteralForms(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"0xFF", token.INT, "0xFF"},
+		{"0Xdead_beef", token.INT, "0Xdead_beef"},
+		{"0x_1F", token.INT, "0x_1F"},
+		{"0o755", token.INT, "0o755"},
+		{"0b1010_0101", token.INT, "0b1010_0101"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1_000.000_5", token.FLOAT, "1_000.000_5"},
+		{"1_000.50d", token.DECIMAL, "1_000.50d"},
+		{"0x", token.ILLEGAL, `malformed number "0x": missing hexadecimal digits`},
+		{"0b_", token.ILLEGAL, `malformed number "0b_": missing binary digits`},
+		{"0o8", token.ILLEGAL, `malformed number "0o8": invalid digit '8' in octal literal`},
+		{"0b102", token.ILLEGAL, `malformed number "0b102": invalid digit '2' in binary literal`},
+		{"0xFG", token.ILLEGAL, `malformed number "0xFG": invalid digit 'G' in hexadecimal literal`},
+		{"0x1__F", token.ILLEGAL, `malformed number "0x1__F": '_' must separate successive digits`},
+		{"1__0", token.ILLEGAL, `malformed number "1__0": '_' must separate successive digits`},
+		{"1_", token.ILLEGAL, `malformed number "1_": '_' must separate successive digits`},
+		{"1_.5", token.ILLEGAL, `malformed number "1_.5": '_' must separate successive digits`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. exprected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. exprected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("tests[%d] - expected EOF after the number. got=%q", i, next.Type)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"a\tb"`, token.STRING, "a\tb"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{48}\u{e9}\u{1F600}"`, token.STRING, "H\u00e9\U0001F600"},
+		{`"unterminated`, token.UNTERMINATED, `"unterminated`},
+		{`"bad \q unterminated`, token.UNTERMINATED, `"bad \q unterminated`},
+		{`"bad \q escape"`, token.ILLEGAL, `"bad \q escape"`},
+		{`"\u{110000}"`, token.ILLEGAL, `"\u{110000}"`},
+		{`"\u{}"`, token.ILLEGAL, `"\u{}"`},
+		{`"\u{41"`, token.ILLEGAL, `"\u{41"`},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. exprected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. exprected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x +\n\"a\nb\" y"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.PLUS, 2, 5},
+		{token.STRING, 3, 1},
+		{token.IDENT, 4, 4},
+		{token.EOF, 4, 5},
+	}
+
+	l := NewWithFilename(input, "test.mk")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. exprected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. exprected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+		if tok.Pos.Filename != "test.mk" {
+			t.Fatalf("tests[%d] - filename wrong. got=%q", i, tok.Pos.Filename)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let café = \"naïve ☃\";\nπ + 日本 € \xff \"a\xffb\" x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "café", 1, 5},
+		{token.ASSIGN, "=", 1, 10},
+		{token.STRING, "naïve ☃", 1, 12},
+		{token.SEMICOLON, ";", 1, 21},
+		{token.IDENT, "π", 2, 1},
+		{token.PLUS, "+", 2, 3},
+		{token.IDENT, "日本", 2, 5},
+		{token.ILLEGAL, "€", 2, 8},
+		{token.ILLEGAL, "invalid UTF-8 encoding", 2, 10},
+		{token.ILLEGAL, "invalid UTF-8 encoding in string", 2, 12},
+		{token.IDENT, "x", 2, 18},
+		{token.EOF, "", 2, 19},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. exprected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. exprected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. exprected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestShebangLine(t *testing.T) {
+	input := "#!/usr/bin/env monkey run\nlet x = 1;"
+
+	l := New(input)
+
+	tok := l.NextToken()
+	if tok.Type != token.LET {
+		t.Fatalf("tokentype wrong. exprected=%q, got=%q", token.LET, tok.Type)
+	}
+	if tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+		t.Fatalf("position wrong. exprected=2:1, got=%s", tok.Pos)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+	let x = 1; // trailing comment
+	/* block
+	   /* nested */ still comment */
+	x / 2 /**/
+	`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing comment"},
+		{token.COMMENT, "/* block\n\t   /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.COMMENT, "/**/"},
+		{token.EOF, ""},
+	}
+
+	for _, emit := range []bool{false, true} {
+		l := New(input)
+		l.SetEmitComments(emit)
+
+		for i, tt := range tests {
+			if tt.expectedType == token.COMMENT && !emit {
+				continue
+			}
+
+			tok := l.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("tests[%d] - tokentype wrong. exprected=%q, got=%q", i, tt.expectedType, tok.Type)
+			}
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("tests[%d] - literal wrong. exprected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	input := "let x = 1;\n  /* outer /* inner */ never closed"
+
+	l := New(input)
+	for i := 0; i < 5; i++ {
+		l.NextToken()
+	}
+
+	tok := l.NextToken()
+	if tok.Type != token.UNTERMINATED {
+		t.Fatalf("tokentype wrong. exprected=%q, got=%q", token.UNTERMINATED, tok.Type)
+	}
+	if tok.Literal != "unterminated block comment" {
+		t.Fatalf("literal wrong. got=%q", tok.Literal)
+	}
+	if tok.Pos.Line != 2 || tok.Pos.Column != 3 {
+		t.Fatalf("position wrong. exprected=2:3, got=%s", tok.Pos)
+	}
+}
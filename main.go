@@ -1,18 +1,163 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/compiler"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/evaluator"
+	"github.com/st0012/monkey/lexer"
+	"github.com/st0012/monkey/object"
+	"github.com/st0012/monkey/parser"
 	"github.com/st0012/monkey/repl"
+	"github.com/st0012/monkey/vm"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
+const usage = `Usage:
+  monkey [flags]                start the interactive REPL
+  monkey [flags] run <file.mk>  run a Monkey script
+  monkey [flags] -e '<source>'  evaluate source and print the result
+
+`
+
 func main() {
+	source := flag.String("e", "", "evaluate the given source and print the result")
+	engine := flag.String("engine", repl.EngineEval, "the engine that executes programs: eval or vm")
+	strict := flag.Bool("strict", false, "make conditions that aren't booleans an error")
+	bigint := flag.Bool("bigint", true, "promote integers that overflow to big integers; with -bigint=false overflowing is an error")
+	rounding := flag.String("rounding", "half_even", "the rounding mode of decimals: half_even, half_up, half_down, up, down, ceiling or floor")
+	modulePath := flag.String("path", os.Getenv("MONKEY_PATH"), "the directories to search for imported modules, separated like $PATH")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// An empty -e is still a program to run, not a request for the REPL.
+	evaluate := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "e" {
+			evaluate = true
+		}
+	})
+
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Fprintf(os.Stderr, "unknown engine %q\n", *engine)
+		os.Exit(2)
+	}
+
+	mode, ok := core.ParseRoundingMode(*rounding)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown rounding mode %q\n", *rounding)
+		os.Exit(2)
+	}
+
+	options := core.DefaultOptions()
+	options.PromoteOnOverflow = *bigint
+	options.DecimalRounding = mode
+	options.StrictConditions = *strict
+	options.ModulePaths = filepath.SplitList(*modulePath)
+
+	switch {
+	case evaluate:
+		os.Exit(run(*source, "", *engine, options, os.Stdout, os.Stderr, true))
+	case flag.NArg() == 0:
+		startREPL(*engine, options)
+	case flag.NArg() == 2 && flag.Arg(0) == "run":
+		os.Exit(runFile(flag.Arg(1), *engine, options))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func startREPL(engine string, options core.Options) {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, engine, options)
+}
+
+func runFile(filename, engine string, options core.Options) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return run(string(src), filename, engine, options, os.Stdout, os.Stderr, false)
+}
+
+// run parses and evaluates src, reporting errors to errOut. It returns the
+// process exit status: 1 on parse errors or an uncaught runtime error.
+func run(src, filename, engine string, options core.Options, out, errOut io.Writer, printResult bool) int {
+	l := lexer.NewWithFilename(src, filename)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(errOut, msg)
+		}
+		return 1
+	}
+
+	rt := core.New(options)
+
+	var evaluated object.Object
+	if engine == repl.EngineVM {
+		var err error
+		if evaluated, err = runVM(program, rt); err != nil {
+			var runtimeErr *object.Error
+			if !errors.As(err, &runtimeErr) {
+				fmt.Fprintln(errOut, "ERROR: "+err.Error())
+				return 1
+			}
+			evaluated = runtimeErr
+		}
+	} else {
+		evaluated = evaluator.New(rt).Eval(program, object.NewEnvironment())
+	}
+
+	if evaluated == nil {
+		return 0
+	}
+
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(errOut, err.Inspect())
+		if trace := err.StackTrace(); trace != "" {
+			fmt.Fprint(errOut, "\n"+trace)
+		}
+		return 1
+	}
+
+	if printResult && evaluated.Type() != object.NULL_OBJ {
+		fmt.Fprintln(out, evaluated.Inspect())
+	}
+
+	return 0
+}
+
+func runVM(program *ast.Program, rt *core.Runtime) (object.Object, error) {
+	comp := compiler.New()
+	comp.SetModulePaths(rt.ModulePaths)
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := vm.New(comp.Bytecode(), rt)
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	return machine.LastPoppedStackElem(), nil
 }
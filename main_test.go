@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/repl"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input          string
+		printResult    bool
+		expectedStatus int
+		expectedOut    string
+		expectedErr    string
+	}{
+		{"1 + 2", true, 0, "3\n", ""},
+		{"1 + 2", false, 0, "", ""},
+		{"", true, 0, "", ""},
+		{`let x = "a"; x`, true, 0, "\"a\"\n", ""},
+		{"if (false) { 1 }", true, 0, "", ""},
+		{"#!/usr/bin/env monkey\n5", true, 0, "5\n", ""},
+		{"let x = ;", true, 1, "", "script.mk:1:9: no prefix function for ;.\n"},
+		{"let x = 1;\nx + y", true, 1, "", "ERROR: script.mk:2:5: identifier not found: y\n"},
+		{
+			"let inner = fn() { x };\nlet outer = fn() { inner() };\nouter()",
+			true,
+			1,
+			"",
+			"ERROR: script.mk:1:20: identifier not found: x\n\n" +
+				"inner()\n\tscript.mk:1:20\n" +
+				"outer()\n\tscript.mk:2:25\n" +
+				"<main>\n\tscript.mk:3:6\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+
+		status := run(tt.input, "script.mk", repl.EngineEval, core.DefaultOptions(), &out, &errOut, tt.printResult)
+
+		if status != tt.expectedStatus {
+			t.Errorf("wrong exit status for %q. expected=%d, got=%d", tt.input, tt.expectedStatus, status)
+		}
+		if out.String() != tt.expectedOut {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expectedOut, out.String())
+		}
+		if errOut.String() != tt.expectedErr {
+			t.Errorf("wrong error output for %q. expected=%q, got=%q", tt.input, tt.expectedErr, errOut.String())
+		}
+	}
+}
+
+func TestRunWithVM(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedStatus int
+		expectedOut    string
+		expectedErr    string
+	}{
+		{"let f = fn(x) { x * 2 }; f(21)", 0, "42\n", ""},
+		{"", 0, "", ""},
+		{"let x = ;", 1, "", "script.mk:1:9: no prefix function for ;.\n"},
+		{"1 + true", 1, "", "ERROR: script.mk:1:3: type mismatch: INTEGER + BOOLEAN\n"},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+
+		status := run(tt.input, "script.mk", repl.EngineVM, core.DefaultOptions(), &out, &errOut, true)
+
+		if status != tt.expectedStatus {
+			t.Errorf("wrong exit status for %q. expected=%d, got=%d", tt.input, tt.expectedStatus, status)
+		}
+		if out.String() != tt.expectedOut {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expectedOut, out.String())
+		}
+		if errOut.String() != tt.expectedErr {
+			t.Errorf("wrong error output for %q. expected=%q, got=%q", tt.input, tt.expectedErr, errOut.String())
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package monkey lets Go programs embed the Monkey language.
+//
+//	interp := monkey.New()
+//	interp.SetGlobal("price", 120)
+//	result, err := interp.Eval(`if (price > 100) { "expensive" } else { "cheap" }`)
+package monkey
+
+import (
+	"fmt"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/evaluator"
+	"github.com/st0012/monkey/lexer"
+	"github.com/st0012/monkey/object"
+	"github.com/st0012/monkey/parser"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Func is a Go function that scripts can call. A returned error is turned
+// into a Monkey runtime error.
+type Func func(args ...object.Object) (object.Object, error)
+
+// Interpreter evaluates Monkey source. Globals defined by one call to Eval
+// are visible to the next ones.
+type Interpreter struct {
+	env       *object.Environment
+	rt        *core.Runtime
+	evaluator *evaluator.Evaluator
+}
+
+// New returns an interpreter with the default options.
+func New() *Interpreter {
+	return NewWithOptions(core.DefaultOptions())
+}
+
+// NewWithOptions returns an interpreter that runs scripts with the given
+// options, like whether integers that overflow become big integers.
+func NewWithOptions(options core.Options) *Interpreter {
+	rt := core.New(options)
+
+	return &Interpreter{
+		env:       object.NewEnvironment(),
+		rt:        rt,
+		evaluator: evaluator.New(rt),
+	}
+}
+
+// Eval parses and evaluates src. The returned error is always an *Error.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &Error{Kind: ParseError, Messages: p.Errors()}
+	}
+
+	evaluated := i.evaluator.Eval(program, i.env)
+	if err, ok := evaluated.(*object.Error); ok {
+		msg := err.Message
+		if err.Pos.IsValid() {
+			msg = err.Pos.String() + ": " + msg
+		}
+		return nil, &Error{Kind: RuntimeError, Messages: []string{msg}, Err: err}
+	}
+
+	if evaluated == nil {
+		return evaluator.NULL, nil
+	}
+
+	return evaluated, nil
+}
+
+// SetGlobal defines a global variable. The value can be an object.Object or
+// a Go value supported by ToObject.
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	i.env.Set(name, obj)
+	return nil
+}
+
+// GetGlobal returns the value of a global variable.
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// RegisterFunc makes fn callable from scripts under the given name.
+func (i *Interpreter) RegisterFunc(name string, fn Func) {
+	builtin := &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			result, err := fn(args...)
+			if err != nil {
+				return &object.Error{Kind: object.RUNTIME_ERROR, Message: err.Error()}
+			}
+			if result == nil {
+				return evaluator.NULL
+			}
+			return result
+		},
+	}
+
+	i.env.Set(name, builtin)
+}
+
+// SetStdout sets where `puts` writes to.
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.rt.Stdout = w
+}
+
+// SetStderr sets where `warn` writes to.
+func (i *Interpreter) SetStderr(w io.Writer) {
+	i.rt.Stderr = w
+}
+
+// ToObject converts a Go value to a Monkey object. It supports nil, bool,
+// integers, floats, strings, slices of supported values, maps with string
+// keys and values that already are objects. A *big.Int becomes an integer,
+// and a *big.Rat a decimal if it can be written as one exactly.
+func ToObject(value interface{}) (object.Object, error) {
+	switch v := value.(type) {
+	case object.Object:
+		return v, nil
+	case nil:
+		return evaluator.NULL, nil
+	case bool:
+		if v {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case int:
+		return &object.Integer{Value: int64(v)}, nil
+	case int32:
+		return &object.Integer{Value: int64(v)}, nil
+	case int64:
+		return &object.Integer{Value: v}, nil
+	case *big.Int:
+		if v.IsInt64() {
+			return &object.Integer{Value: v.Int64()}, nil
+		}
+		return &object.BigInt{Value: new(big.Int).Set(v)}, nil
+	case float32:
+		return &object.Float{Value: float64(v)}, nil
+	case float64:
+		return &object.Float{Value: v}, nil
+	case *big.Rat:
+		if d, ok := decimalFromRat(v); ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("cannot convert %s to a decimal exactly", v.RatString())
+	case string:
+		return &object.String{Value: v}, nil
+	case []interface{}:
+		elements := make([]object.Object, len(v))
+		for i, el := range v {
+			obj, err := ToObject(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	case map[string]interface{}:
+		// Go maps are unordered, so keys are sorted to keep Inspect stable.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		hash := object.NewHash()
+		for _, key := range keys {
+			obj, err := ToObject(v[key])
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&object.String{Value: key}, obj)
+		}
+		return hash, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to a Monkey object", value)
+	}
+}
+
+// decimalFromRat returns r as a decimal, if its digits end. That's when
+// the denominator only has the factors 2 and 5, so it divides a power of
+// ten no bigger than 10 to the denominator's bit length.
+func decimalFromRat(r *big.Rat) (*object.Decimal, bool) {
+	value := new(big.Int).Set(r.Num())
+	remainder := new(big.Int)
+	ten := big.NewInt(10)
+
+	for scale := 0; scale <= r.Denom().BitLen(); scale++ {
+		quotient, _ := new(big.Int).QuoRem(value, r.Denom(), remainder)
+		if remainder.Sign() == 0 {
+			return &object.Decimal{Value: quotient, Scale: scale}, true
+		}
+		value.Mul(value, ten)
+	}
+
+	return nil, false
+}
+
+type ErrorKind int
+
+const (
+	ParseError ErrorKind = iota
+	RuntimeError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ParseError:
+		return "parse error"
+	case RuntimeError:
+		return "runtime error"
+	default:
+		return "unknown error"
+	}
+}
+
+// Error is returned by Interpreter.Eval.
+type Error struct {
+	Kind ErrorKind
+	// Messages holds each parser error, or the runtime error, with its position.
+	Messages []string
+	// Err is the uncaught error object of a runtime error.
+	Err *object.Error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.String() + ": " + strings.Join(e.Messages, "; ")
+}
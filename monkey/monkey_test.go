@@ -0,0 +1,253 @@
+package monkey
+
+import (
+	"bytes"
+	"errors"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/object"
+	"math/big"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	interp := New()
+
+	if _, err := interp.Eval("let double = fn(x) { x * 2 };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Eval("double(21)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	result, err = interp.Eval("")
+	if err != nil || result.Type() != object.NULL_OBJ {
+		t.Errorf("empty source should evaluate to null. got=%v, %v", result, err)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    ErrorKind
+		expectedMessage string
+	}{
+		{"let x = ;", ParseError, "parse error: 1:9: no prefix function for ;."},
+		{"let x = 1;\nx + true", RuntimeError, "runtime error: 2:3: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		_, err := New().Eval(tt.input)
+
+		var evalErr *Error
+		if !errors.As(err, &evalErr) {
+			t.Fatalf("error is not *Error. got=%T (%v)", err, err)
+		}
+		if evalErr.Kind != tt.expectedKind {
+			t.Errorf("wrong kind. expected=%s, got=%s", tt.expectedKind, evalErr.Kind)
+		}
+		if evalErr.Error() != tt.expectedMessage {
+			t.Errorf("wrong message. expected=%q, got=%q", tt.expectedMessage, evalErr.Error())
+		}
+		if tt.expectedKind == RuntimeError && evalErr.Err == nil {
+			t.Errorf("runtime error doesn't carry the error object")
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	options := core.DefaultOptions()
+	options.PromoteOnOverflow = false
+	options.DecimalRounding = core.RoundDown
+	options.StrictConditions = true
+
+	configured := NewWithOptions(options)
+	defaults := New()
+
+	tests := []struct {
+		input      string
+		configured string
+		defaults   string
+	}{
+		{"9223372036854775807 + 1", "runtime error: 1:21: integer overflow: 9223372036854775807 + 1", "9223372036854775808"},
+		{"2d / 3", "0.6666666666666666d", "0.6666666666666667d"},
+		{"if (1) { 2 }", "runtime error: 1:1: condition must be BOOLEAN, got INTEGER", "2"},
+	}
+
+	for _, tt := range tests {
+		for _, run := range []struct {
+			interp   *Interpreter
+			expected string
+		}{{configured, tt.configured}, {defaults, tt.defaults}} {
+			result, err := run.interp.Eval(tt.input)
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = result.Inspect()
+			}
+
+			if got != run.expected {
+				t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, run.expected, got)
+			}
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	interp := New()
+
+	values := map[string]interface{}{
+		"count": 3,
+		"name":  "monkey",
+		"admin": true,
+		"tags":  []interface{}{"a", 1},
+		"user":  map[string]interface{}{"name": "x", "age": int64(7)},
+		"none":  nil,
+	}
+	for name, value := range values {
+		if err := interp.SetGlobal(name, value); err != nil {
+			t.Fatalf("SetGlobal(%q) failed: %s", name, err)
+		}
+	}
+
+	result, err := interp.Eval(`let greeting = name + "!"; [count, greeting, admin, tags, user, none]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `[3, "monkey!", true, ["a", 1], {"age": 7, "name": "x"}, null]`
+	if result.Inspect() != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, result.Inspect())
+	}
+
+	greeting, ok := interp.GetGlobal("greeting")
+	if !ok || greeting.Inspect() != `"monkey!"` {
+		t.Errorf("wrong global. got=%v", greeting)
+	}
+
+	if _, ok := interp.GetGlobal("missing"); ok {
+		t.Errorf("missing global should not be found")
+	}
+
+	if err := interp.SetGlobal("bad", complex(1, 2)); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+
+	if err := interp.SetGlobal("third", big.NewRat(1, 3)); err == nil {
+		t.Errorf("expected an error for a fraction that isn't a decimal")
+	}
+}
+
+func TestNumberGlobals(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{1.5, "1.5"},
+		{float32(0.25), "0.25"},
+		{big.NewInt(5), "5"},
+		{huge, "123456789012345678901234567890"},
+		{big.NewRat(5, 4), "1.25d"},
+		{big.NewRat(-1, 8), "-0.125d"},
+		{big.NewRat(3, 1), "3d"},
+	}
+
+	for _, tt := range tests {
+		interp := New()
+		if err := interp.SetGlobal("x", tt.value); err != nil {
+			t.Fatalf("SetGlobal(%v) failed: %s", tt.value, err)
+		}
+
+		result, err := interp.Eval("x")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong value for %v. expected=%s, got=%s", tt.value, tt.expected, result.Inspect())
+		}
+	}
+
+	interp := New()
+	interp.RegisterFunc("half", func(args ...object.Object) (object.Object, error) {
+		return ToObject(float64(args[0].(*object.Integer).Value) / 2)
+	})
+
+	result, err := interp.Eval("half(3) + 1")
+	if err != nil || result.Inspect() != "2.5" {
+		t.Errorf("wrong result. got=%v, %v", result, err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interp := New()
+
+	interp.RegisterFunc("add", func(args ...object.Object) (object.Object, error) {
+		sum := int64(0)
+		for _, arg := range args {
+			integer, ok := arg.(*object.Integer)
+			if !ok {
+				return nil, errors.New("add only takes integers")
+			}
+			sum += integer.Value
+		}
+		return &object.Integer{Value: sum}, nil
+	})
+	interp.RegisterFunc("nothing", func(args ...object.Object) (object.Object, error) {
+		return nil, nil
+	})
+
+	result, err := interp.Eval("add(1, 2, 3)")
+	if err != nil || result.Inspect() != "6" {
+		t.Errorf("wrong result. got=%v, %v", result, err)
+	}
+
+	result, err = interp.Eval("nothing()")
+	if err != nil || result.Type() != object.NULL_OBJ {
+		t.Errorf("wrong result. got=%v, %v", result, err)
+	}
+
+	_, err = interp.Eval(`add(1, "2")`)
+	if err == nil || err.Error() != "runtime error: 1:4: add only takes integers" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestOutputWriters(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	interp := New()
+	interp.SetStdout(&stdout)
+	interp.SetStderr(&stderr)
+
+	if _, err := interp.Eval(`puts("hello", 1); warn("careful")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stdout.String() != "hello\n1\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "careful\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+
+	// The writers aren't script bindings, so a script can't see or undo them.
+	if _, ok := interp.GetGlobal("puts"); ok {
+		t.Errorf("puts shouldn't be a global")
+	}
+
+	stdout.Reset()
+	if _, err := interp.Eval(`let f = fn() { let puts = 1; puts }; f(); puts("again")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if stdout.String() != "again\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+}
@@ -2,7 +2,8 @@ package object
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	c := make(map[string]bool)
+	return &Environment{store: s, consts: c, outer: nil}
 }
 
 func NewClosedEnvironment(outer *Environment) *Environment {
@@ -13,7 +14,9 @@ func NewClosedEnvironment(outer *Environment) *Environment {
 
 type Environment struct {
 	store map[string]Object
-	outer *Environment
+	// consts marks the bindings of store declared with `const`.
+	consts map[string]bool
+	outer  *Environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +31,58 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// SetConst binds name like Set and marks the binding as constant.
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.consts[name] = true
+	return e.Set(name, val)
+}
+
+// IsConst reports whether name resolves to a constant binding.
+func (e *Environment) IsConst(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.consts[name]
+	}
+	if e.outer != nil {
+		return e.outer.IsConst(name)
+	}
+	return false
+}
+
+// IsConstInScope reports whether name is a constant of this environment
+// itself, ignoring the outer ones.
+func (e *Environment) IsConstInScope(name string) bool {
+	return e.consts[name]
+}
+
+// Consts returns the names declared constant in this environment itself.
+func (e *Environment) Consts() map[string]bool {
+	consts := make(map[string]bool, len(e.consts))
+	for name := range e.consts {
+		consts[name] = true
+	}
+	return consts
+}
+
+// ResetConsts forgets the constants declared since Consts returned consts,
+// so they can be declared again. The bindings themselves are kept.
+func (e *Environment) ResetConsts(consts map[string]bool) {
+	for name := range e.consts {
+		if !consts[name] {
+			delete(e.consts, name)
+		}
+	}
+}
+
+// Assign updates an existing binding in the innermost environment that has
+// it. It reports false if the name isn't bound anywhere.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return nil, false
+}
@@ -4,6 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/code"
+	"github.com/st0012/monkey/token"
+	"hash/fnv"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -11,11 +16,24 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIG_INT_OBJ      = "BIG_INT"
+	FLOAT_OBJ        = "FLOAT"
+	DECIMAL_OBJ      = "DECIMAL"
+	STRING_OBJ       = "STRING"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
 	FUNCTION_OBJ     = "FUNCTION"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BUILTIN_OBJ      = "BUILTIN"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -23,6 +41,32 @@ type Object interface {
 	Inspect() string
 }
 
+// Truthy is implemented by objects that decide for themselves whether they
+// count as true in a condition. Objects that don't implement it are true.
+type Truthy interface {
+	Object
+	Truthy() bool
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+func IsTruthy(obj Object) bool {
+	if truthy, ok := obj.(Truthy); ok {
+		return truthy.Truthy()
+	}
+	return true
+}
+
+// Hashable is implemented by objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
 type Integer struct {
 	Value int64
 }
@@ -35,6 +79,178 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// BigInt holds an integer that doesn't fit in an Integer. Arithmetic
+// only produces one when the result is out of the int64 range.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bi *BigInt) Type() ObjectType {
+	return BIG_INT_OBJ
+}
+
+func (bi *BigInt) Inspect() string {
+	return bi.Value.String()
+}
+
+func (bi *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(bi.Value.Bytes())
+
+	value := h.Sum64()
+	if bi.Value.Sign() < 0 {
+		value = ^value
+	}
+
+	return HashKey{Type: bi.Type(), Value: value}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect always shows a float as one, so `3.0` isn't printed as `3`.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// Decimal is an exact base-10 number: the unscaled Value divided by
+// 10**Scale. `12.50d` has a Value of 1250 and a Scale of 2.
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+func (d *Decimal) Type() ObjectType {
+	return DECIMAL_OBJ
+}
+
+// Inspect keeps the scale and the suffix, so `12.50d` is printed as it's
+// written.
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Value).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+
+	if d.Value.Sign() < 0 {
+		digits = "-" + digits
+	}
+
+	return digits + "d"
+}
+
+// HashKey ignores trailing zeros, so `1.5d` and `1.50d` are the same key,
+// and a whole number has the key of the equal integer, as `1d == 1`.
+func (d *Decimal) HashKey() HashKey {
+	value := new(big.Int).Set(d.Value)
+	scale := d.Scale
+
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	for scale > 0 {
+		quotient, _ := new(big.Int).QuoRem(value, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		value = quotient
+		scale--
+	}
+
+	if scale == 0 {
+		if value.IsInt64() {
+			return (&Integer{Value: value.Int64()}).HashKey()
+		}
+		return (&BigInt{Value: value}).HashKey()
+	}
+
+	h := fnv.New64a()
+	h.Write(value.Bytes())
+	fmt.Fprintf(h, "e%d", scale)
+
+	hash := h.Sum64()
+	if value.Sign() < 0 {
+		hash = ^hash
+	}
+
+	return HashKey{Type: d.Type(), Value: hash}
+}
+
+// maxDecimalExponent bounds the exponent ParseDecimal accepts, so a number
+// like `1e999999999` is rejected instead of taking forever to build.
+const maxDecimalExponent = 1000000
+
+// ParseDecimal parses a decimal number without the `d` suffix, like
+// `12.50`, `-3` or `1.5e3`.
+func ParseDecimal(s string) (*Decimal, bool) {
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > maxDecimalExponent || e < -maxDecimalExponent {
+			return nil, false
+		}
+		mantissa, exponent = s[:i], e
+	}
+
+	negative := strings.HasPrefix(mantissa, "-")
+	if negative || strings.HasPrefix(mantissa, "+") {
+		mantissa = mantissa[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	digits := integer + fraction
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return nil, false
+	}
+
+	value, _ := new(big.Int).SetString(digits, 10)
+	scale := len(fraction) - exponent
+	if scale < 0 {
+		value.Mul(value, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+
+	if negative {
+		value.Neg(value)
+	}
+
+	return &Decimal{Value: value, Scale: scale}, true
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return strconv.Quote(s.Value)
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -47,6 +263,20 @@ func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
 
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (b *Boolean) Truthy() bool {
+	return b.Value
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -57,6 +287,10 @@ func (n *Null) Inspect() string {
 	return "null"
 }
 
+func (n *Null) Truthy() bool {
+	return false
+}
+
 type ReturnValue struct {
 	Value Object
 }
@@ -69,8 +303,85 @@ func (r *ReturnValue) Inspect() string {
 	return r.Value.Inspect()
 }
 
+// Break and Continue signal a `break` or `continue` statement to the
+// enclosing loop, just like ReturnValue does for the enclosing function.
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// Kinds of errors. Scripts can also throw errors of kinds of their own.
+const (
+	// RUNTIME_ERROR is the kind of the errors the interpreter raises.
+	RUNTIME_ERROR = "RuntimeError"
+	// THROWN_ERROR is the kind of errors thrown with just a message.
+	THROWN_ERROR = "Error"
+)
+
+// Error is an error on its way up the evaluation, until a catch block or
+// the top level stops it.
 type Error struct {
+	Kind    string
 	Message string
+	Pos     token.Position
+	// Trace holds the function calls the error came out of, innermost
+	// first.
+	Trace []Frame
+}
+
+// Frame is a call of a function, by name if it has one.
+type Frame struct {
+	Function string
+	Pos      token.Position
+}
+
+// maxTraceFrames is how many frames StackTrace shows at most.
+const maxTraceFrames = 100
+
+// StackTrace formats the trace like a Go panic does: each function with
+// where it was at below it, innermost first. It's empty if the error didn't
+// come out of a function.
+func (e *Error) StackTrace() string {
+	if len(e.Trace) == 0 {
+		return ""
+	}
+
+	var out bytes.Buffer
+
+	pos := e.Pos
+	for i, frame := range e.Trace {
+		if i == maxTraceFrames {
+			out.WriteString("...additional frames elided...\n")
+			break
+		}
+
+		name := frame.Function
+		if name == "" {
+			name = "<anonymous>"
+		}
+
+		fmt.Fprintf(&out, "%s()\n\t%s\n", name, pos)
+		pos = frame.Pos
+	}
+
+	fmt.Fprintf(&out, "<main>\n\t%s\n", e.Trace[len(e.Trace)-1].Pos)
+
+	return out.String()
 }
 
 func (e *Error) Type() ObjectType {
@@ -78,10 +389,39 @@ func (e *Error) Type() ObjectType {
 }
 
 func (e *Error) Inspect() string {
-	return "ERROR: " + e.Message
+	return "ERROR: " + e.Error()
+}
+
+// Error makes an Error usable as a Go error, which is how the vm reports
+// it.
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+	return e.Message
+}
+
+// ErrorValue is an error as a value, like the one a catch block gets.
+// Unlike an Error, it doesn't abort the evaluation until it's thrown.
+type ErrorValue struct {
+	Kind    string
+	Message string
+	Pos     token.Position
+	// Trace is the trace of the caught error, kept so throwing the value
+	// again doesn't lose where the error came from.
+	Trace []Frame
+}
+
+func (ev *ErrorValue) Type() ObjectType {
+	return ERROR_VALUE_OBJ
+}
+
+func (ev *ErrorValue) Inspect() string {
+	return ev.Kind + ": " + ev.Message
 }
 
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -92,10 +432,16 @@ func (f *Function) Type() ObjectType {
 }
 
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Parameters, f.Body)
+}
+
+// inspectFunction prints a function as its source, the same way with
+// either engine.
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 
@@ -103,8 +449,177 @@ func (f *Function) Inspect() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
+
+// BuiltinFunction is a function implemented by the host program.
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function"
+}
+
+// Module is an imported file. Its exports always have their current values:
+// the evaluator looks them up in the environment the file was evaluated in,
+// and the vm in the global slots the compiler gave them.
+type Module struct {
+	Path    string
+	Env     *Environment
+	Exports map[string]bool
+	// Globals maps each export to its global slot when the vm runs the module.
+	Globals map[string]int
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
+func (m *Module) Inspect() string {
+	return "module " + strconv.Quote(m.Path)
+}
+
+// Member returns the exported binding with the given name.
+func (m *Module) Member(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash keeps its keys in insertion order so Inspect output is deterministic.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+type CompiledFunction struct {
+	// Name is the name of the binding the function is defined with, if any.
+	Name          string
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// LocalNames holds the name of each local slot, for error messages.
+	LocalNames []string
+	// FreeVariables describes where a closure over this function captures
+	// each of its free variables from.
+	FreeVariables []FreeVariable
+	// Positions maps the offset of each instruction to the position of the
+	// source it was compiled from, so runtime errors can point at it.
+	Positions map[int]token.Position
+	// Literal is the function's source, which Inspect prints. The function
+	// that runs a module has none.
+	Literal *ast.FunctionExpression
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Literal == nil {
+		return "fn() {...}"
+	}
+	return inspectFunction(cf.Literal.Parameters, cf.Literal.BlockStatement)
+}
+
+// FreeVariable refers to either a local slot or a free variable of the
+// function that encloses the closure.
+type FreeVariable struct {
+	Name  string
+	Local bool
+	Index int
+}
+
+// Closure is the vm's function value. It shares each captured variable with
+// the scope it was captured from, so assignments are visible on both sides.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Object
+}
+
+// Type reports FUNCTION_OBJ so scripts see the same type under both engines.
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	return c.Fn.Inspect()
+}
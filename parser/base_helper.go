@@ -24,6 +24,18 @@ func (p *Parser) curPrecedence() int {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braces++
+	case token.RBRACE:
+		p.braces--
+	}
+
+	// Comments don't affect the program, even if the lexer keeps them.
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -35,8 +47,12 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.syntaxError(Diagnostic{
+		Pos:      p.peekToken.Pos,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type),
+		Expected: t,
+		Got:      p.peekToken.Type,
+	})
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
@@ -49,6 +65,15 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// skipSemicolon moves past the optional `;` that ends a statement. After a
+// syntax error it leaves the tokens alone, since the error may have stopped
+// the parser before the `}` that really ends the statement's block.
+func (p *Parser) skipSemicolon() {
+	if p.peekTokenIs(token.SEMICOLON) && !p.panicking {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -58,6 +83,35 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix function for %s.", t)
-	p.errors = append(p.errors, msg)
+	p.syntaxError(Diagnostic{
+		Pos:     p.curToken.Pos,
+		Message: fmt.Sprintf("no prefix function for %s.", t),
+		Got:     t,
+	})
+}
+
+// addError records an error message prefixed with its source position.
+func (p *Parser) addError(pos token.Position, format string, args ...interface{}) {
+	p.report(Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// declare records a binding in the current scope, reporting it if it
+// redeclares a constant.
+func (p *Parser) declare(pos token.Position, name string, constant bool) {
+	scope := p.scopes[len(p.scopes)-1]
+	if scope[name] {
+		p.addError(pos, "cannot redeclare constant: %s", name)
+	}
+	scope[name] = constant
+}
+
+// isConst reports whether name refers to a constant, as far as the parser
+// can tell from the declarations it has seen so far.
+func (p *Parser) isConst(name string) bool {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if constant, ok := p.scopes[i][name]; ok {
+			return constant
+		}
+	}
+	return false
 }
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"github.com/st0012/monkey/token"
+)
+
+// Severity tells how serious a diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic is a problem the parser found in the source.
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position
+	Message  string
+
+	// Expected and Got are the token types involved when the parser ran
+	// into a token it didn't expect. Expected is empty when any token
+	// that starts an expression would have done.
+	Expected token.TokenType
+	Got      token.TokenType
+}
+
+// String returns the diagnostic as it's printed, prefixed with its position.
+func (d Diagnostic) String() string {
+	return d.Pos.String() + ": " + d.Message
+}
+
+// report records d, unless the parser is still recovering from an earlier
+// syntax error or d repeats a diagnostic it already has. Either way, d
+// would be noise that hides the first, real error.
+func (p *Parser) report(d Diagnostic) {
+	if p.panicking {
+		return
+	}
+
+	for _, seen := range p.diagnostics {
+		if seen.Pos == d.Pos && seen.Message == d.Message {
+			return
+		}
+	}
+
+	p.diagnostics = append(p.diagnostics, d)
+	if d.Severity == SeverityError {
+		p.errors = append(p.errors, d.String())
+	}
+}
+
+// syntaxError reports a token that doesn't fit the grammar, and puts the
+// parser in panic mode until synchronize finds the next statement.
+func (p *Parser) syntaxError(d Diagnostic) {
+	p.report(d)
+	p.panicking = true
+}
+
+// synchronize skips the rest of a statement that had a syntax error, and
+// leaves curToken at the start of the next one: after a `;`, or on a `let`,
+// `const`, `return` or the `}` that closes the enclosing block. Any other
+// `}` closes a brace of the broken statement and is skipped. start is
+// where the broken statement began, which is skipped even if it's a
+// boundary, so the parser always moves forward.
+func (p *Parser) synchronize(start token.Token) {
+	p.panicking = false
+
+	if p.curToken.Pos == start.Pos && !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
+
+	for {
+		switch p.curToken.Type {
+		case token.SEMICOLON:
+			p.nextToken()
+			return
+		case token.LET, token.CONST, token.RETURN, token.EOF:
+			return
+		case token.RBRACE:
+			if len(p.blocks) > 0 && p.braces == p.blocks[len(p.blocks)-1]-1 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
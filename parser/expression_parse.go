@@ -1,33 +1,66 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/object"
 	"github.com/st0012/monkey/token"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 var precedence = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+
+	token.AND:       LOGICAL_AND,
+	token.OR:        LOGICAL_OR,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LT_EQ:     LESSGREATER,
+	token.GT_EQ:     LESSGREATER,
+	token.PIPE:      BITWISE_OR,
+	token.CARET:     BITWISE_XOR,
+	token.AMPERSAND: BITWISE_AND,
+	token.LSHIFT:    SHIFT,
+	token.RSHIFT:    SHIFT,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.POWER:     POWER,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
+	token.DOT:       INDEX,
 }
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
+	BITWISE_OR
+	BITWISE_XOR
+	BITWISE_AND
+	SHIFT
 	SUM
 	PRODUCT
+	POWER
 	PREFIX
 	CALL
+	INDEX
 )
 
 type (
@@ -55,17 +88,34 @@ func (p *Parser) parseExpression(precendence int) ast.Expression {
 	return leftExp
 }
 
+// parseIllegal reports a token the lexer couldn't make sense of. Its literal
+// is either the offending source text or a description of the problem.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.syntaxError(Diagnostic{
+		Pos:     p.curToken.Pos,
+		Message: "illegal token: " + p.curToken.Literal,
+		Got:     p.curToken.Type,
+	})
+	return nil
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseIntegerLiteral parses an integer, which is a BigIntegerLiteral if
+// it doesn't fit in an int64.
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(lit.TokenLiteral(), 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(lit.TokenLiteral(), 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: value}
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", lit.TokenLiteral())
-		p.errors = append(p.errors, msg)
+		p.addError(lit.Pos(), "could not parse %q as integer", lit.TokenLiteral())
 		return nil
 	}
 
@@ -74,13 +124,46 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(lit.TokenLiteral(), 64)
+	if err != nil {
+		p.addError(lit.Pos(), "could not parse %q as float", lit.TokenLiteral())
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	lit := &ast.DecimalLiteral{Token: p.curToken}
+
+	digits := strings.ReplaceAll(strings.TrimSuffix(lit.TokenLiteral(), "d"), "_", "")
+	value, ok := object.ParseDecimal(digits)
+	if !ok {
+		p.addError(lit.Pos(), "could not parse %q as decimal", lit.TokenLiteral())
+		return nil
+	}
+
+	lit.Value = value.Value
+	lit.Scale = value.Scale
+
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	lit := &ast.Boolean{Token: p.curToken}
 
 	value, err := strconv.ParseBool(lit.TokenLiteral())
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as boolean", lit.TokenLiteral())
-		p.errors = append(p.errors, msg)
+		p.addError(lit.Pos(), "could not parse %q as boolean", lit.TokenLiteral())
 		return nil
 	}
 
@@ -110,12 +193,42 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	// `**` is right associative, so `2 ** 3 ** 2` is `2 ** (3 ** 2)`.
+	if exp.Operator == "**" {
+		precedence--
+	}
 	p.nextToken()
 	exp.Right = p.parseExpression(precedence)
 
 	return exp
 }
 
+// parseAssignExpression parses an assignment. It's right associative, so
+// `a = b = 1` assigns 1 to both.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   left,
+		Operator: p.curToken.Literal,
+	}
+
+	switch left := left.(type) {
+	case *ast.Identifier:
+		if p.isConst(left.Value) {
+			p.addError(exp.Pos(), "cannot assign to constant: %s", left.Value)
+		}
+	case *ast.IndexExpression:
+	default:
+		p.addError(exp.Pos(), "invalid assignment target: %s", left.String())
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(ASSIGN - 1)
+
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -155,7 +268,19 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 		return nil
 	}
 
+	scope := map[string]bool{}
+	for _, param := range fe.Parameters {
+		scope[param.Value] = false
+	}
+	p.scopes = append(p.scopes, scope)
+
+	// A function body starts outside of any loop.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	fe.BlockStatement = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	p.scopes = p.scopes[:len(p.scopes)-1]
 
 	return fe
 }
@@ -189,32 +314,96 @@ func (p *Parser) parseParameters() []*ast.Identifier {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken() // ')'
-		return args
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseMemberExpression parses `module.name`.
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// parseHashLiteral parses `{key: value, ...}`. A `{` in expression position is
+// always a hash literal; block statements are only parsed where the grammar
+// expects them (after `if`, `else` and `fn(...)`).
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
+
+// parseExpressionList parses comma separated expressions until the given end token.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken() // end
+		return list
 	}
 
 	p.nextToken() // start of first expression
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // ","
 		p.nextToken() // start of next expression
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
@@ -222,10 +411,28 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	bs := &ast.BlockStatement{Token: p.curToken}
 	bs.Statements = []ast.Statement{}
 
+	p.blocks = append(p.blocks, p.braces)
+	defer func() { p.blocks = p.blocks[:len(p.blocks)-1] }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.syntaxError(Diagnostic{
+				Pos:      p.curToken.Pos,
+				Message:  fmt.Sprintf("expected next token to be %s, got %s instead", token.RBRACE, token.EOF),
+				Expected: token.RBRACE,
+				Got:      token.EOF,
+			})
+			return bs
+		}
+
+		start := p.curToken
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		if stmt != nil {
 			bs.Statements = append(bs.Statements, stmt)
 		}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"github.com/st0012/monkey/ast"
+)
+
+// checkJumps reports the `break` and `continue` statements that would leave
+// an expression before it's finished, like the one in `1 + if (x) { break }`.
+// A jump has to be a statement of a loop body, or of an if that's a
+// statement of its own in there. inLoop tells whether node is in a loop of
+// the current function, and inExpression whether its value is used.
+func (p *Parser) checkJumps(node ast.Node, inLoop, inExpression bool) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			p.checkJumps(stmt, inLoop, false)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			p.checkJumps(stmt, inLoop, inExpression)
+		}
+	case *ast.ExpressionStatement:
+		// An if's value is only used if it's part of a bigger expression.
+		_, isIf := node.Expression.(*ast.IfExpression)
+		p.checkJumps(node.Expression, inLoop, inExpression || !isIf)
+	case *ast.BreakStatement:
+		if inLoop && inExpression {
+			p.addError(node.Pos(), "break inside of an expression")
+		}
+	case *ast.ContinueStatement:
+		if inLoop && inExpression {
+			p.addError(node.Pos(), "continue inside of an expression")
+		}
+	case *ast.WhileStatement:
+		p.checkJumps(node.Condition, inLoop, true)
+		p.checkJumps(node.Body, true, false)
+	case *ast.ForStatement:
+		p.checkJumps(node.Iterable, inLoop, true)
+		p.checkJumps(node.Body, true, false)
+	case *ast.TryStatement:
+		p.checkJumps(node.Body, inLoop, inExpression)
+		if node.Catch != nil {
+			p.checkJumps(node.Catch, inLoop, inExpression)
+		}
+		if node.Finally != nil {
+			p.checkJumps(node.Finally, inLoop, inExpression)
+		}
+	case *ast.IfExpression:
+		p.checkJumps(node.Condition, inLoop, true)
+		p.checkJumps(node.Consequence, inLoop, inExpression)
+		if node.Alternative != nil {
+			p.checkJumps(node.Alternative, inLoop, inExpression)
+		}
+	case *ast.FunctionExpression:
+		p.checkJumps(node.BlockStatement, false, false)
+	case *ast.LetStatement:
+		p.checkJumps(node.Value, inLoop, true)
+	case *ast.ExportStatement:
+		p.checkJumps(node.Statement, inLoop, inExpression)
+	case *ast.ReturnStatement:
+		p.checkJumps(node.ReturnValue, inLoop, true)
+	case *ast.ThrowStatement:
+		p.checkJumps(node.Value, inLoop, true)
+	case *ast.PrefixExpression:
+		p.checkJumps(node.Right, inLoop, true)
+	case *ast.InfixExpression:
+		p.checkJumps(node.Left, inLoop, true)
+		p.checkJumps(node.Right, inLoop, true)
+	case *ast.AssignExpression:
+		p.checkJumps(node.Target, inLoop, true)
+		p.checkJumps(node.Value, inLoop, true)
+	case *ast.CallExpression:
+		p.checkJumps(node.Function, inLoop, true)
+		for _, arg := range node.Arguments {
+			p.checkJumps(arg, inLoop, true)
+		}
+	case *ast.IndexExpression:
+		p.checkJumps(node.Left, inLoop, true)
+		p.checkJumps(node.Index, inLoop, true)
+	case *ast.MemberExpression:
+		p.checkJumps(node.Object, inLoop, true)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			p.checkJumps(el, inLoop, true)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			p.checkJumps(pair.Key, inLoop, true)
+			p.checkJumps(pair.Value, inLoop, true)
+		}
+	}
+}
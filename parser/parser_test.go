@@ -113,6 +113,115 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testIntegerLiteral(t, literal, 5)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"99_999_999_999_999_999_999", "99999999999999999999"},
+		{"0xFFFFFFFFFFFFFFFFF", "295147905179352825855"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value.String() != tt.expected {
+			t.Errorf("literal.Value not %s. got=%s", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	input := `3.25;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("first program statement is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != 3.25 {
+		t.Errorf("literal.Value not %f. got=%f", 3.25, literal.Value)
+	}
+}
+
+func TestDecimalLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue int64
+		expectedScale int
+	}{
+		{"12.50d", 1250, 2},
+		{"3d", 3, 0},
+		{".5d", 5, 1},
+		{"1.5e3d", 1500, 0},
+		{"25e-3d", 25, 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.DecimalLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value.Int64() != tt.expectedValue || literal.Scale != tt.expectedScale {
+			t.Errorf("wrong value for %s. expected=%d (scale %d), got=%s (scale %d)",
+				tt.input, tt.expectedValue, tt.expectedScale, literal.Value, literal.Scale)
+		}
+
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -290,6 +399,58 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"f(x)[0]",
+			"(f(x)[0])",
+		},
+		{
+			"-util.helper(a.b)[0]",
+			"(-((util.helper)((a.b))[0]))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c < d || !e",
+			"(((a == b) && (c < d)) || (!e))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a + b % c ** d ** e",
+			"(a + (b % (c ** (d ** e))))",
+		},
+		{
+			"a | b ^ c & d << e + f",
+			"(a | (b ^ (c & (d << (e + f)))))",
+		},
+		{
+			"a < b | c",
+			"(a < (b | c))",
+		},
+		{
+			"~a >> -b",
+			"((~a) >> (-b))",
+		},
 
 	}
 
@@ -341,7 +502,7 @@ func TestIfExpression(t *testing.T) {
 	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
 
 	if !ok {
-		t.Errorf("expect consequence should be an expression statement. got=t", exp.Consequence.Statements[0])
+		t.Errorf("expect consequence should be an expression statement. got=%T", exp.Consequence.Statements[0])
 	}
 
 	if !testInfixExpression(t, consequence.Expression, "x", "+", 5) {
@@ -351,7 +512,7 @@ func TestIfExpression(t *testing.T) {
 	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
 
 	if !ok {
-		t.Errorf("expect alternative should be an expression statement. got=t", exp.Alternative.Statements[0])
+		t.Errorf("expect alternative should be an expression statement. got=%T", exp.Alternative.Statements[0])
 	}
 
 	if !testInfixExpression(t, alternative.Expression, "y", "+", 4) {
@@ -359,6 +520,37 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestFunctionName(t *testing.T) {
+	input := `let myFunction = fn() { }; const other = fn() { }; fn() { }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{"myFunction", "other"}
+	for i, name := range expected {
+		stmt, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not ast.LetStatement. got=%T", i, program.Statements[i])
+		}
+
+		function, ok := stmt.Value.(*ast.FunctionExpression)
+		if !ok {
+			t.Fatalf("stmt.Value is not ast.FunctionExpression. got=%T", stmt.Value)
+		}
+
+		if function.Name != name {
+			t.Errorf("function name wrong. want %q, got %q", name, function.Name)
+		}
+	}
+
+	anonymous := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionExpression)
+	if anonymous.Name != "" {
+		t.Errorf("anonymous function has a name. got %q", anonymous.Name)
+	}
+}
+
 func TestFunctionExpression(t *testing.T) {
 	input := `fn(x, y) { x + y };`
 
@@ -452,6 +644,521 @@ func TestCallExpression(t *testing.T) {
 	testInfixExpression(t, returnStmt.ReturnValue, "x", "+", "y")
 }
 
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiterals(t *testing.T) {
+	input := "[]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, 3: 3, true: 4}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 4 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	if hash.String() != `{"one": 1, "two": 2, 3: 3, true: 4}` {
+		t.Errorf("hash.String() wrong. got=%s", hash.String())
+	}
+
+	expectedKeys := []interface{}{"one", "two", 3, true}
+	for i, pair := range hash.Pairs {
+		switch expected := expectedKeys[i].(type) {
+		case string:
+			literal, ok := pair.Key.(*ast.StringLiteral)
+			if !ok || literal.Value != expected {
+				t.Errorf("key %d is not string %q. got=%s", i, expected, pair.Key)
+			}
+		default:
+			testLiteralExpression(t, pair.Key, expected)
+		}
+		testIntegerLiteral(t, pair.Value, int64(i+1))
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `if (true) { {"one": 0 + 1, "two": 10 - 8} }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	ifExp := stmt.Expression.(*ast.IfExpression)
+	inner := ifExp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := inner.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", inner.Expression)
+	}
+
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+}
+
+func TestConstStatements(t *testing.T) {
+	input := `
+const x = 5;
+let f = fn(x) { x = 1; let y = 2; y = 3 };
+let g = fn() { let x = 2; x = 3 };
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	if !stmt.IsConst() {
+		t.Errorf("stmt.IsConst() is false")
+	}
+
+	if stmt.String() != "const x = 5" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))"},
+		{"x += 2 * 3", "(x += (2 * 3))"},
+		{"x -= 1; x *= 2; x /= 3; x %= 4", "(x -= 1)(x *= 2)(x /= 3)(x %= 4)"},
+		{"a[0] = b[1]", "((a[0]) = (b[1]))"},
+		{`h["k"] += 1`, `((h["k"]) += 1)`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[2] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParam string
+		hasCatch      bool
+		hasFinally    bool
+		expected      string
+	}{
+		{`try { f(); } catch (e) { g(e); }`, "e", true, false, "try f() catch (e) g(e)"},
+		{`try { f(); } finally { g(); }`, "", false, true, "try f() finally g()"},
+		{`try { f(); } catch { g(); } finally { h(); }`, "", true, true, "try f() catch g() finally h()"},
+		{`throw error("oops");`, "", false, false, `throw error("oops");`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+
+		stmt, ok := program.Statements[0].(*ast.TryStatement)
+		if !ok {
+			if _, ok := program.Statements[0].(*ast.ThrowStatement); !ok {
+				t.Errorf("program.Statements[0] is not ast.TryStatement or ast.ThrowStatement. got=%T", program.Statements[0])
+			}
+			continue
+		}
+
+		if (stmt.Catch != nil) != tt.hasCatch || (stmt.Finally != nil) != tt.hasFinally {
+			t.Errorf("wrong blocks for %s. catch=%t, finally=%t", tt.input, stmt.Catch != nil, stmt.Finally != nil)
+		}
+
+		if tt.expectedParam != "" && (stmt.CatchParam == nil || stmt.CatchParam.Value != tt.expectedParam) {
+			t.Errorf("catch parameter is not %q. got=%v", tt.expectedParam, stmt.CatchParam)
+		}
+	}
+}
+
+func TestModuleStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/util.mk" as util;`, `import "lib/util.mk" as util`},
+		{`export let x = 1;`, "export let x = 1"},
+		{`export const f = fn(a) { a };`, "export const f = fn(a) { a }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+
+		switch stmt := program.Statements[0].(type) {
+		case *ast.ImportStatement:
+			if stmt.Path.Value != "lib/util.mk" || stmt.Name.Value != "util" {
+				t.Errorf("wrong import. path=%q, name=%q", stmt.Path.Value, stmt.Name.Value)
+			}
+		case *ast.ExportStatement:
+			if stmt.Statement == nil {
+				t.Errorf("export has no let statement")
+			}
+		default:
+			t.Errorf("program.Statements[0] is not ast.ImportStatement or ast.ExportStatement. got=%T", stmt)
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { x }; x`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("stmt.Iterable wrong. got=%q", stmt.Iterable.String())
+	}
+
+	if stmt.String() != "for (x in [1, 2]) x" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	input := `/* header */ let x = 5; // five
+	x /* inline */ + 1`
+
+	for _, emit := range []bool{false, true} {
+		l := lexer.New(input)
+		l.SetEmitComments(emit)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != "let x = 5(x + 1)" {
+			t.Errorf("program.String() wrong. got=%q", program.String())
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x 5;", "test.mk:1:7: expected next token to be =, got INT instead"},
+		{"let x = 1;\nadd(1, 2", "test.mk:2:9: expected next token to be ), got EOF instead"},
+		{"let x = 1;\n  let = 5;", "test.mk:2:7: expected next token to be IDENT, got = instead"},
+		{"5 + ;", "test.mk:1:5: no prefix function for ;."},
+		{"if (x) {\n  1", "test.mk:2:4: expected next token to be }, got EOF instead"},
+		{"let x = 1;\n/* oops", "test.mk:2:1: illegal token: unterminated block comment"},
+		{`"abc`, `test.mk:1:1: illegal token: "abc`},
+		{"let x = \xff;", "test.mk:1:9: illegal token: invalid UTF-8 encoding"},
+		{"let x = 0x;", `test.mk:1:9: illegal token: malformed number "0x": missing hexadecimal digits`},
+		{"if (x) { break }", "test.mk:1:10: break outside of loop"},
+		{"while (x) { fn() { continue } }", "test.mk:1:20: continue outside of loop"},
+		{"while (x) { let y = if (x) { break } }", "test.mk:1:30: break inside of an expression"},
+		{"while (x) { 1 + if (x) { break } else { 2 } }", "test.mk:1:26: break inside of an expression"},
+		{"for (i in x) { [if (i) { continue } else { i }] }", "test.mk:1:26: continue inside of an expression"},
+		{"while (x) { if (x) { break } + 1 }", "test.mk:1:22: break inside of an expression"},
+		{"for (1 in x) { }", "test.mk:1:6: expected next token to be IDENT, got INT instead"},
+		{"1 + x = 2", "test.mk:1:7: invalid assignment target: (1 + x)"},
+		{"const x = 1; x = 2", "test.mk:1:16: cannot assign to constant: x"},
+		{"const x = 1;\nlet f = fn() { x += 1 }", "test.mk:2:18: cannot assign to constant: x"},
+		{"const x = 1; let x = 2", "test.mk:1:14: cannot redeclare constant: x"},
+		{"const x = 1; for (x in []) {}", "test.mk:1:14: cannot redeclare constant: x"},
+		{"try { 1 }", "test.mk:1:1: try without catch or finally"},
+		{"try { 1 } catch (1) { }", "test.mk:1:18: expected next token to be IDENT, got INT instead"},
+		{"import util", "test.mk:1:8: expected next token to be STRING, got IDENT instead"},
+		{`import "util.mk" util`, "test.mk:1:18: expected next token to be AS, got IDENT instead"},
+		{"export fn() {}", "test.mk:1:8: expected next token to be LET, got FUCTION instead"},
+		{"let f = fn() { export let x = 1 }", "test.mk:1:16: export is only allowed at the top level"},
+		{"util.(x)", "test.mk:1:6: expected next token to be IDENT, got ( instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFilename(tt.input, "test.mk")
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements string
+	}{
+		{
+			"let x = add(1, 2;\nlet y = 3;\ny",
+			[]string{"1:17: expected next token to be ), got ; instead"},
+			"let y = 3y",
+		},
+		{
+			"let = 5;\nlet y = ;\nreturn 1",
+			[]string{"1:5: expected next token to be IDENT, got = instead", "2:9: no prefix function for ;."},
+			"return 1;",
+		},
+		{
+			"let f = fn(x) { let y = ; x }; f(1)",
+			[]string{"1:25: no prefix function for ;."},
+			"let f = fn(x) { x }f(1)",
+		},
+		{
+			"fn() { 1 + }; let z = 1",
+			[]string{"1:12: no prefix function for }."},
+			"fn() {  }let z = 1",
+		},
+		{
+			"let a = [1, 2; let b = 3 }",
+			[]string{"1:14: expected next token to be ], got ; instead", "1:26: no prefix function for }."},
+			"let b = 3",
+		},
+		{"x + }", []string{"1:5: no prefix function for }."}, ""},
+		{
+			`let h = {"a": 1 "b": 2}; let q = 1`,
+			[]string{"1:17: expected next token to be ,, got STRING instead"},
+			"let q = 1",
+		},
+		{
+			"let r = try { 1 } catch (e) { 2 }; r",
+			[]string{"1:9: no prefix function for TRY."},
+			"r",
+		},
+		{
+			`let f = fn() { let h = {"a": 1 "b": 2}; 1 }; f()`,
+			[]string{"1:32: expected next token to be ,, got STRING instead"},
+			"let f = fn() { 1 }f()",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+
+		for i, expected := range tt.expectedErrors {
+			if errors[i] != expected {
+				t.Errorf("wrong error. expected=%q, got=%q", expected, errors[i])
+			}
+		}
+
+		if program.String() != tt.expectedStatements {
+			t.Errorf("wrong statements for %q. expected=%q, got=%q", tt.input, tt.expectedStatements, program.String())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	l := lexer.NewWithFilename("let x 5;\nbreak;", "test.mk")
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics. expected=2, got=%d", len(diagnostics))
+	}
+
+	d := diagnostics[0]
+	if d.Severity != SeverityError {
+		t.Errorf("wrong severity. expected=%s, got=%s", SeverityError, d.Severity)
+	}
+	if d.Pos.Line != 1 || d.Pos.Column != 7 {
+		t.Errorf("wrong position. got=%s", d.Pos)
+	}
+	if d.Expected != token.ASSIGN || d.Got != token.INT {
+		t.Errorf("wrong tokens. expected=%s and %s, got=%s and %s", token.ASSIGN, token.INT, d.Expected, d.Got)
+	}
+	if d.String() != p.Errors()[0] {
+		t.Errorf("diagnostic and error don't match. diagnostic=%q, error=%q", d.String(), p.Errors()[0])
+	}
+
+	d = diagnostics[1]
+	if d.Message != "break outside of loop" || d.Expected != "" || d.Got != "" {
+		t.Errorf("wrong diagnostic. got=%+v", d)
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let. got=%q", s.TokenLiteral())
@@ -534,7 +1241,7 @@ func testInfixExpression(
 ) bool {
 	opExp, ok := exp.(*ast.InfixExpression)
 	if !ok {
-		t.Errorf("exp is not ast.OperatorExpression. got=%T", exp, exp)
+		t.Errorf("exp is not ast.OperatorExpression. got=%T", exp)
 		return false
 	}
 
@@ -565,7 +1272,7 @@ func testBoolLiteral(t *testing.T, exp ast.Expression, v bool) bool {
 	}
 
 	if bo.TokenLiteral() != fmt.Sprintf("%t", v) {
-		t.Errorf("bo.TokenLiteral is not %t. got=%t", v, exp.TokenLiteral())
+		t.Errorf("bo.TokenLiteral is not %t. got=%s", v, exp.TokenLiteral())
 	}
 
 	return true
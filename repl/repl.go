@@ -3,42 +3,178 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/compiler"
+	"github.com/st0012/monkey/core"
 	"github.com/st0012/monkey/evaluator"
 	"github.com/st0012/monkey/lexer"
+	"github.com/st0012/monkey/object"
 	"github.com/st0012/monkey/parser"
+	"github.com/st0012/monkey/token"
+	"github.com/st0012/monkey/vm"
 	"io"
-	"github.com/st0012/monkey/object"
+	"strings"
 )
 
 const PROMT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// CONTINUATION_PROMT is shown while the input so far is an incomplete statement.
+const CONTINUATION_PROMT = ".. "
+
+// Engines that can execute Monkey programs.
+const (
+	EngineEval = "eval"
+	EngineVM   = "vm"
+)
+
+// session holds what a REPL keeps between inputs: the environment of the
+// evaluator and the compiled state of the vm. Modules aren't kept, so
+// importing a file again picks up its changes.
+type session struct {
+	engine string
+	rt     *core.Runtime
+	env    *object.Environment
+
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+}
+
+func newSession(engine string, options core.Options) *session {
+	rt := core.New(options)
+
+	return &session{
+		engine:      engine,
+		rt:          rt,
+		env:         object.NewEnvironment(),
+		constants:   []object.Object{},
+		symbolTable: compiler.NewSymbolTable(),
+	}
+}
+
+// Start runs a REPL that reads from in and writes to out, executing each
+// input with the given engine and options.
+func Start(in io.Reader, out io.Writer, engine string, options core.Options) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := newSession(engine, options)
+
+	var input strings.Builder
 
 	for {
-		fmt.Printf(PROMT)
+		if input.Len() == 0 {
+			io.WriteString(out, PROMT)
+		} else {
+			io.WriteString(out, CONTINUATION_PROMT)
+		}
+
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+
+		// An empty line ends the input even if it's incomplete, so a mistake
+		// can't keep the prompt waiting forever.
+		force := input.Len() != 0 && strings.TrimSpace(line) == ""
+
+		input.WriteString(line)
+		input.WriteString("\n")
+
+		src := input.String()
+		l := lexer.New(src)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
+		if !force && isIncomplete(src, p.Diagnostics()) {
+			continue
+		}
+
+		input.Reset()
+
 		if len(p.Errors()) != 0 {
 			printParserErrors(out, p.Errors())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated, err := s.evaluate(program)
+		if err != nil {
+			io.WriteString(out, "ERROR: "+err.Error()+"\n")
+			continue
+		}
+
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
+
+			if err, ok := evaluated.(*object.Error); ok && err.StackTrace() != "" {
+				io.WriteString(out, "\n"+err.StackTrace())
+			}
+		}
+	}
+}
+
+// evaluate runs the program with the session's engine. A Go panic in the
+// engine is reported as an internal error instead of ending the REPL.
+func (s *session) evaluate(program *ast.Program) (evaluated object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	if s.engine != EngineVM {
+		return evaluator.New(s.rt).Eval(program, s.env), nil
+	}
+
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	comp.SetModulePaths(s.rt.ModulePaths)
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	bytecode := comp.Bytecode()
+	s.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, s.rt, s.globals)
+	err = machine.Run()
+	s.globals = machine.Globals()
+	if err != nil {
+		return nil, err
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
+
+// isIncomplete reports whether src is a statement the user hasn't finished
+// typing: it has unclosed brackets, strings or comments, or the parser ran
+// out of input, which is also what happens after a trailing operator.
+func isIncomplete(src string, diagnostics []parser.Diagnostic) bool {
+	l := lexer.New(src)
+	depth := 0
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.UNTERMINATED:
+			return true
 		}
 	}
+
+	if depth > 0 {
+		return true
+	}
+
+	for _, d := range diagnostics {
+		if d.Got == token.EOF {
+			return true
+		}
+	}
+
+	return false
 }
 
 func printParserErrors(out io.Writer, errors []string) {
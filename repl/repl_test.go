@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/core"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + 2\n",
+			">> 3\n>> ",
+		},
+		{
+			"let sum = fn(x, y) {\n  x + y\n}(1, 2);\nsum\n",
+			">> .. .. 3\n>> 3\n>> ",
+		},
+		{
+			"let x = 1 +\n2;\nx\n",
+			">> .. 3\n>> 3\n>> ",
+		},
+		{
+			"[1,\n2]\n",
+			">> .. [1, 2]\n>> ",
+		},
+		{
+			"\"multi\nline\"\n",
+			">> .. \"multi\\nline\"\n>> ",
+		},
+		{
+			"\"bad \\q\nescape\"\n",
+			">> .. \t1:1: illegal token: \"bad \\q\nescape\"\n>> ",
+		},
+		{
+			"/* a\ncomment */ 5\n",
+			">> .. 5\n>> ",
+		},
+		{
+			"if (true) {\n\n",
+			">> .. \t3:1: expected next token to be }, got EOF instead\n>> ",
+		},
+		{
+			"1 +) 2\n",
+			">> \t1:4: no prefix function for ).\n>> ",
+		},
+		{
+			"1 + EOF = 2\n",
+			">> \t1:9: invalid assignment target: (1 + EOF)\n>> ",
+		},
+	}
+
+	for _, engine := range []string{EngineEval, EngineVM} {
+		for _, tt := range tests {
+			var out bytes.Buffer
+
+			Start(strings.NewReader(tt.input), &out, engine, core.DefaultOptions())
+
+			got := out.String()
+			if got != tt.expected {
+				t.Errorf("wrong output with %s for %q.\nexpected=%q\ngot=%q", engine, tt.input, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestEvaluateRecoversFromPanics(t *testing.T) {
+	// An identifier without a value makes both engines panic.
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: (*ast.Identifier)(nil)},
+	}}
+
+	for _, engine := range []string{EngineEval, EngineVM} {
+		s := newSession(engine, core.DefaultOptions())
+
+		_, err := s.evaluate(program)
+		if err == nil || !strings.HasPrefix(err.Error(), "internal error: ") {
+			t.Errorf("expected an internal error with %s. got=%v", engine, err)
+		}
+	}
+}
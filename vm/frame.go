@@ -0,0 +1,29 @@
+package vm
+
+import (
+	"github.com/st0012/monkey/code"
+	"github.com/st0012/monkey/object"
+)
+
+type Frame struct {
+	cl *object.Closure
+	ip int
+	// basePointer is the stack pointer to restore when the frame returns.
+	basePointer int
+	// locals live on the heap so closures can keep referring to them after
+	// the frame has returned.
+	locals []object.Object
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+		locals:      make([]object.Object, cl.Fn.NumLocals),
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
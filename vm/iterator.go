@@ -0,0 +1,20 @@
+package vm
+
+import (
+	"github.com/st0012/monkey/object"
+)
+
+// iterator holds the state of a for loop. It only ever lives on the stack
+// between OpIter and the end of the loop.
+type iterator struct {
+	elements []object.Object
+	index    int
+}
+
+func (i *iterator) Type() object.ObjectType {
+	return "ITERATOR"
+}
+
+func (i *iterator) Inspect() string {
+	return "iterator"
+}
@@ -0,0 +1,609 @@
+package vm
+
+import (
+	"errors"
+	"github.com/st0012/monkey/code"
+	"github.com/st0012/monkey/compiler"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/object"
+)
+
+const (
+	// StackSize is how many values the stack can hold. It starts out
+	// smaller and grows as it fills up.
+	StackSize = 1 << 20
+	// MaxFrames is the main frame plus the most calls that can nest.
+	MaxFrames = core.MaxCallDepth + 1
+
+	initialStackSize = 2048
+	initialFrames    = 64
+)
+
+var errStackOverflow = errors.New("stack overflow")
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpBang:   "!",
+	code.OpMinus:  "-",
+	code.OpBitNot: "~",
+}
+
+type VM struct {
+	rt        *core.Runtime
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	globals      []object.Object
+	globalNames  []string
+	constGlobals []bool
+
+	frames      []*Frame
+	framesIndex int
+
+	// imported holds the functions of the modules that ran already.
+	imported map[*object.CompiledFunction]bool
+
+	// handlers are the try statements that are running, innermost last.
+	handlers []handler
+}
+
+// handler is where an error raised in a try statement goes: the frame and
+// the stack pointer the statement started with, and its catch code.
+type handler struct {
+	framesIndex int
+	sp          int
+	catchPos    int
+}
+
+// New returns a vm that runs bytecode with the operators and builtins of rt.
+func New(bytecode *compiler.Bytecode, rt *core.Runtime) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Positions: bytecode.Positions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, initialFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		rt:        rt,
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, initialStackSize),
+		sp:    0,
+
+		globals:      make([]object.Object, len(bytecode.GlobalNames)),
+		globalNames:  bytecode.GlobalNames,
+		constGlobals: bytecode.ConstGlobals,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		imported: map[*object.CompiledFunction]bool{},
+	}
+}
+
+// NewWithGlobalsStore returns a vm that starts with the given globals, so a
+// REPL session can keep its variables between runs. The store grows to fit
+// the globals of the bytecode; Globals returns it for the next run.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, rt *core.Runtime, s []object.Object) *VM {
+	vm := New(bytecode, rt)
+	if missing := len(bytecode.GlobalNames) - len(s); missing > 0 {
+		s = append(s, make([]object.Object, missing)...)
+	}
+	vm.globals = s
+	return vm
+}
+
+// Globals returns the values of the globals.
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// LastPoppedStackElem returns the value of the last expression statement.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run executes the bytecode. A runtime error is returned as an
+// *object.Error, with the same message and position the evaluator reports.
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+	var frame *Frame
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		frame = vm.currentFrame()
+		ip = frame.ip
+		ins = frame.Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			// A big integer literal is an overflow unless the runtime
+			// promotes integers.
+			if literal, ok := vm.constants[constIndex].(*object.BigInt); ok {
+				err = vm.pushResult(vm.rt.BigInteger(literal.Value))
+			} else {
+				err = vm.push(vm.constants[constIndex])
+			}
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(vm.rt.Infix(left, infixOperators[op], right))
+		case code.OpBang, code.OpMinus, code.OpBitNot:
+			right := vm.pop()
+
+			err = vm.pushResult(vm.rt.Prefix(prefixOperators[op], right))
+
+		case code.OpTrue:
+			err = vm.push(core.TRUE)
+		case code.OpFalse:
+			err = vm.push(core.FALSE)
+		case code.OpNull:
+			err = vm.push(core.NULL)
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			truthy, condErr := vm.rt.Condition(vm.pop())
+			if condErr != nil {
+				err = condErr
+			} else if !truthy {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			truthy, condErr := vm.rt.Condition(vm.stack[vm.sp-1])
+			if condErr != nil {
+				err = condErr
+			} else if truthy == (op == code.OpJumpTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpIter:
+			iterable := vm.pop()
+
+			elements, iterErr := core.Iterate(iterable)
+			if iterErr != nil {
+				err = iterErr
+			} else {
+				err = vm.push(&iterator{elements: elements})
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iter := vm.stack[vm.sp-1].(*iterator)
+			if iter.index < len(iter.elements) {
+				err = vm.push(iter.elements[iter.index])
+				iter.index++
+			} else {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.pushVariable(vm.globals[globalIndex], vm.globalNames, int(globalIndex))
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.assignGlobal(int(globalIndex), vm.pop())
+		case code.OpGetLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.pushVariable(frame.locals[localIndex], frame.cl.Fn.LocalNames, int(localIndex))
+		case code.OpSetLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.currentFrame().locals[localIndex] = vm.pop()
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cl := vm.currentFrame().cl
+			if value := *cl.Free[freeIndex]; value != nil {
+				err = vm.push(value)
+			} else {
+				err = core.NewError("identifier not found: %s", cl.Fn.FreeVariables[freeIndex].Name)
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			*vm.currentFrame().cl.Free[freeIndex] = vm.pop()
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.rt.Builtin(int(builtinIndex)))
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err == nil {
+				vm.sp = vm.sp - numElements
+				err = vm.push(hash)
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(core.Index(left, index))
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(core.SetIndex(left, index, value))
+		case code.OpDupPair:
+			err = vm.push(vm.stack[vm.sp-2])
+			if err == nil {
+				err = vm.push(vm.stack[vm.sp-2])
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// A return outside of any function ends the program.
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer
+			vm.dropHandlers()
+
+			err = vm.push(returnValue)
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.pushClosure(int(constIndex))
+
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.importModule(vm.constants[constIndex].(*object.CompiledFunction))
+		case code.OpGetMember:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[constIndex].(*object.String).Value
+			err = vm.pushMember(vm.pop(), name)
+
+		case code.OpTry:
+			catchPos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{framesIndex: vm.framesIndex, sp: vm.sp, catchPos: catchPos})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpThrow:
+			err = core.Throw(vm.pop())
+		}
+
+		if err != nil {
+			rtErr := vm.runtimeError(err, frame, ip)
+			if !vm.catch(rtErr) {
+				return rtErr
+			}
+		}
+	}
+
+	return nil
+}
+
+// catch unwinds the frames and the stack to the innermost running try
+// statement, and pushes err as the error value its catch code expects. It
+// reports false if no try statement is running.
+func (vm *VM) catch(err *object.Error) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	// The trace ends at the frame of the try statement, like it does in
+	// the evaluator.
+	err.Trace = err.Trace[:len(err.Trace)-len(vm.trace(h.framesIndex))]
+
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.catchPos - 1
+
+	return vm.push(core.Catch(err)) == nil
+}
+
+// dropHandlers removes the handlers of the frames that returned.
+func (vm *VM) dropHandlers() {
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+}
+
+// runtimeError turns err into an *object.Error and, unless it has one
+// already, gives it the position of the instruction at ip in frame. The
+// calls it's raised in are added to its trace.
+func (vm *VM) runtimeError(err error, frame *Frame, ip int) *object.Error {
+	rtErr, ok := err.(*object.Error)
+	if !ok {
+		rtErr = core.NewError("%s", err)
+	}
+
+	if !rtErr.Pos.IsValid() {
+		rtErr.Pos = frame.cl.Fn.Positions[ip]
+	}
+	rtErr.Trace = append(rtErr.Trace, vm.trace(vm.framesIndex)...)
+
+	return rtErr
+}
+
+// trace returns the calls of the first framesIndex frames, innermost first,
+// each with the position it was called from. Running a module isn't a call.
+func (vm *VM) trace(framesIndex int) []object.Frame {
+	trace := []object.Frame{}
+
+	for i := framesIndex - 1; i > 0; i-- {
+		fn := vm.frames[i].cl.Fn
+		if vm.imported[fn] {
+			continue
+		}
+
+		caller := vm.frames[i-1]
+		trace = append(trace, object.Frame{Function: fn.Name, Pos: caller.cl.Fn.Positions[caller.ip-1]})
+	}
+
+	return trace
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, make([]*Frame, len(vm.frames))...)
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return errStackOverflow
+	}
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// pushResult pushes the result of an operation, or returns it if it's an
+// *object.Error.
+func (vm *VM) pushResult(result object.Object) error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	return vm.push(result)
+}
+
+// pushVariable pushes the value of a variable slot. A slot that was never
+// assigned belongs to a name that hasn't been defined yet.
+func (vm *VM) pushVariable(value object.Object, names []string, index int) error {
+	if value == nil {
+		return core.NewError("identifier not found: %s", names[index])
+	}
+	return vm.push(value)
+}
+
+func (vm *VM) assignGlobal(index int, value object.Object) error {
+	switch {
+	case vm.globals[index] == nil:
+		return core.NewError("identifier not found: %s", vm.globalNames[index])
+	case vm.constGlobals[index]:
+		return core.NewError("cannot assign to constant: %s", vm.globalNames[index])
+	}
+
+	vm.globals[index] = value
+	return nil
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, core.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return core.NewError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return core.NewError("wrong arguments: expect=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return errStackOverflow
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs-1)
+	copy(frame.locals, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp = frame.basePointer
+
+	vm.pushFrame(frame)
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = core.NULL
+	}
+
+	return vm.pushResult(result)
+}
+
+// importModule calls the function that runs a module, unless it ran
+// already, in which case it pushes null in place of the function's result.
+func (vm *VM) importModule(fn *object.CompiledFunction) error {
+	if vm.imported[fn] {
+		return vm.push(core.NULL)
+	}
+	vm.imported[fn] = true
+
+	if err := vm.push(&object.Closure{Fn: fn}); err != nil {
+		return err
+	}
+	return vm.callClosure(vm.stack[vm.sp-1].(*object.Closure), 0)
+}
+
+func (vm *VM) pushMember(obj object.Object, name string) error {
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return core.NewError("member access not supported: %s.%s", obj.Type(), name)
+	}
+
+	index, ok := module.Globals[name]
+	if !ok {
+		return core.NewError("module %s has no export named %s", module.Path, name)
+	}
+
+	return vm.pushVariable(vm.globals[index], vm.globalNames, index)
+}
+
+func (vm *VM) pushClosure(constIndex int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return core.NewError("not a function: %+v", constant)
+	}
+
+	frame := vm.currentFrame()
+	free := make([]*object.Object, len(function.FreeVariables))
+	for i, fv := range function.FreeVariables {
+		if fv.Local {
+			free[i] = &frame.locals[fv.Index]
+		} else {
+			free[i] = frame.cl.Free[fv.Index]
+		}
+	}
+
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
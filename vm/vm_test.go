@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"github.com/st0012/monkey/ast"
+	"github.com/st0012/monkey/compiler"
+	"github.com/st0012/monkey/core"
+	"github.com/st0012/monkey/lexer"
+	"github.com/st0012/monkey/object"
+	"github.com/st0012/monkey/parser"
+	"testing"
+)
+
+// Most of the vm's behavior is covered by the conformance suite in the
+// evaluator package. These tests cover what's specific to the vm.
+
+func TestRecursiveFunctions(t *testing.T) {
+	input := `
+	let fibonacci = fn(x) {
+		if (x == 0) { return 0; }
+		if (x == 1) { return 1; }
+		fibonacci(x - 1) + fibonacci(x - 2);
+	};
+	let wrapper = fn() {
+		let countDown = fn(x) { if (x == 0) { 0 } else { countDown(x - 1) } };
+		countDown(5) + fibonacci(15);
+	};
+	wrapper();`
+
+	result, err := run(t, input)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	testIntegerObject(t, result, 610)
+}
+
+func TestClosuresOutliveTheirFrame(t *testing.T) {
+	input := `
+	let newAdderOuter = fn(a, b) {
+		let c = a + b;
+		fn(d) {
+			let e = d + c;
+			fn(f) { e + f; };
+		};
+	};
+	let newAdderInner = newAdderOuter(1, 2);
+	let adder = newAdderInner(3);
+	adder(8);`
+
+	result, err := run(t, input)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	testIntegerObject(t, result, 14)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { f() }; f()", "1:17: stack overflow"},
+		{"let f = fn() { if (false) { let x = 1 }; x }; f()", "1:42: identifier not found: x"},
+		{"let f = fn() { g() }; f()", "1:16: identifier not found: g"},
+		{`{"a": 1}[[]]`, "1:9: unusable as hash key: ARRAY"},
+		{`{[]: 1}`, "1:1: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if err == nil {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	constants := []object.Object{}
+	globals := []object.Object{}
+	symbolTable := compiler.NewSymbolTable()
+	rt := core.New(core.DefaultOptions())
+
+	for _, line := range []string{"let a = 1;", "let b = fn() { a + 1 };", "b() + a"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(line)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsStore(bytecode, rt, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		globals = machine.Globals()
+
+		if line == "b() + a" {
+			testIntegerObject(t, machine.LastPoppedStackElem(), 3)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func run(t *testing.T, input string) (object.Object, error) {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode(), core.New(core.DefaultOptions()))
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Fatalf("object is not Integer. got=%T (%+v)", obj, obj)
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. want=%d, got=%d", expected, result.Value)
+	}
+}